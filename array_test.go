@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStringSliceRoundTrip(t *testing.T) {
+	for _, slice := range []StringSlice{
+		{"a"},
+		{"a,b", "{c}", `say "hi"`, `back\slash`, "", " padded ", "NULL"},
+		{"ünïcode", "日本"},
+	} {
+		value, err := slice.Value()
+		if err != nil {
+			t.Fatalf("Value(%q): %v", slice, err)
+		}
+		var scanned StringSlice
+		if err = scanned.Scan(value); err != nil {
+			t.Fatalf("Scan(%v): %v", value, err)
+		}
+		if !reflect.DeepEqual(scanned, slice) {
+			t.Errorf("round trip of %q = %q", slice, scanned)
+		}
+	}
+}
+
+func TestStringSliceScan(t *testing.T) {
+	tests := []struct {
+		src  any
+		want StringSlice
+	}{
+		{nil, nil},
+		{"{}", StringSlice{}},
+		{[]byte(`{a,"b c","x\"y","x\\y"}`), StringSlice{"a", "b c", `x"y`, `x\y`}},
+		{`{"NULL"}`, StringSlice{"NULL"}},
+	}
+	for _, test := range tests {
+		var scanned StringSlice
+		if err := scanned.Scan(test.src); err != nil || !reflect.DeepEqual(scanned, test.want) {
+			t.Errorf("Scan(%v) = %q, %v, want %q", test.src, scanned, err, test.want)
+		}
+	}
+
+	for src, want := range map[string]string{
+		"{a,NULL}":      "element 1 is NULL",
+		"{{a,b},{c,d}}": "nested arrays are not supported",
+		`{"a",b"}`:      "unexpected quote",
+		`{"a}`:          "unterminated quoted element",
+		`{"a"b}`:        "unexpected",
+		"a,b":           "expected a value like {a,b,c}",
+		"":              "expected a value like {a,b,c}",
+		"{":             "expected a value like {a,b,c}",
+		"x":             "expected a value like {a,b,c}",
+	} {
+		var scanned StringSlice
+		if err := scanned.Scan(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Scan(%q) = %v, want an error containing %q", src, err, want)
+		}
+	}
+}
+
+func TestArraySliceEmptyValueIsNull(t *testing.T) {
+	for _, slice := range []driver.Valuer{StringSlice{}, IntSlice(nil), NullableStringSlice{}, TimeSlice{}} {
+		if value, err := slice.Value(); value != nil || err != nil {
+			t.Errorf("%T.Value() = %v, %v, want NULL", slice, value, err)
+		}
+	}
+}
+
+func TestNullableStringSliceRoundTrip(t *testing.T) {
+	text, quoted := "a,b", "NULL"
+	slice := NullableStringSlice{&text, nil, &quoted}
+	value, err := slice.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	if value != `{"a,b",NULL,"NULL"}` {
+		t.Errorf("Value() = %v", value)
+	}
+
+	var scanned NullableStringSlice
+	if err = scanned.Scan(value); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(scanned) != 3 || *scanned[0] != text || scanned[1] != nil || *scanned[2] != quoted {
+		t.Errorf("round trip = %v", scanned)
+	}
+
+	if err = scanned.Scan("{{a}}"); err == nil {
+		t.Error("Scan() of a nested array succeeded")
+	}
+}
+
+func TestIntSliceRoundTrip(t *testing.T) {
+	slice := IntSlice{1, -2, 9223372036854775807}
+	value, err := slice.Value()
+	if err != nil || value != "{1,-2,9223372036854775807}" {
+		t.Fatalf("Value() = %v, %v", value, err)
+	}
+	var scanned IntSlice
+	if err = scanned.Scan([]byte(value.(string))); err != nil || !reflect.DeepEqual(scanned, slice) {
+		t.Errorf("round trip = %v, %v", scanned, err)
+	}
+
+	for _, src := range []string{"{1,x}", "{{1,2}}", "1,2"} {
+		if err = scanned.Scan(src); err == nil {
+			t.Errorf("Scan(%q) succeeded", src)
+		}
+	}
+}
+
+func TestTimeSliceRoundTrip(t *testing.T) {
+	india := time.FixedZone("", 5*3600+30*60)
+	slice := TimeSlice{
+		time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.UTC),
+		time.Date(2026, 7, 8, 9, 10, 11, 0, india),
+	}
+	value, err := slice.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	var scanned TimeSlice
+	if err = scanned.Scan(value); err != nil {
+		t.Fatalf("Scan(%v): %v", value, err)
+	}
+	for i := range slice {
+		if !scanned[i].Equal(slice[i]) {
+			t.Errorf("element %d = %v, want %v", i, scanned[i], slice[i])
+		}
+	}
+}
+
+func TestTimeSliceScanServerFormats(t *testing.T) {
+	var scanned TimeSlice
+	src := `{"2026-01-02 03:04:05.5+00","2026-01-02 03:04:05+05:30","2026-01-02 03:04:05+05:53:28","2026-01-02 03:04:05"}`
+	if err := scanned.Scan(src); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	want := []time.Time{
+		time.Date(2026, 1, 2, 3, 4, 5, 500000000, time.UTC),
+		time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("", 5*3600+30*60)),
+		time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("", 5*3600+53*60+28)),
+		time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	for i := range want {
+		if !scanned[i].Equal(want[i]) {
+			t.Errorf("element %d = %v, want %v", i, scanned[i], want[i])
+		}
+	}
+
+	if err := scanned.Scan(`{"2026-01-02 03:04:05+00",NULL}`); err == nil {
+		t.Error("Scan() with a NULL element succeeded")
+	}
+}
+
+func TestManyScansArrayFields(t *testing.T) {
+	db := newFakeDriver(func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+		return fakeResult{
+			columns: []string{"id", "tags", "scores"},
+			rows: [][]driver.Value{
+				{int64(1), []byte(`{admin,"on call"}`), []byte("{1,2}")},
+				{int64(2), []byte("{}"), nil},
+			},
+		}, nil
+	}).client(t)
+
+	type user struct {
+		ID     int64       `db:"id"`
+		Tags   StringSlice `db:"tags"`
+		Scores IntSlice    `db:"scores"`
+	}
+	var users []user
+	if _, err := db.Select("SELECT id, tags, scores FROM users", &users).Many(context.Background()); err != nil {
+		t.Fatalf("Many: %v", err)
+	}
+	want := []user{
+		{ID: 1, Tags: StringSlice{"admin", "on call"}, Scores: IntSlice{1, 2}},
+		{ID: 2, Tags: StringSlice{}},
+	}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("Many = %+v, want %+v", users, want)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !pgx
+
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// batchSupported reports whether this build can send a pipeline as a single batch.
+// Batching requires the pgx driver, which is only compiled in with the "pgx" build tag.
+const batchSupported = false
+
+// sendBatch is not available without the "pgx" build tag.
+func sendBatch(ctx context.Context, conn *sqlx.Conn, steps []batchStep) ([]any, error) {
+	return nil, errors.New("batch execution requires building with the pgx build tag")
+}
@@ -0,0 +1,90 @@
+//go:build pgx
+
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// batchSupported reports whether this build can send a pipeline as a single batch.
+const batchSupported = true
+
+// sendBatch queues every step into a single pgx batch and sends it in one round-trip.
+// It must be called with a connection that already has the pipeline transaction open,
+// so the batch runs inside that transaction.
+func sendBatch(ctx context.Context, conn *sqlx.Conn, steps []batchStep) ([]any, error) {
+	results := make([]any, len(steps))
+
+	err := conn.Raw(func(driverConn any) error {
+		for {
+			wrapped, ok := driverConn.(interface{ Unwrap() driver.Conn })
+			if !ok {
+				break
+			}
+			driverConn = wrapped.Unwrap()
+		}
+
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.Errorf("batch execution requires the pgx driver, got %T", driverConn)
+		}
+
+		batch := &pgx.Batch{}
+		for _, step := range steps {
+			query, arguments, err := sqlx.Named(escapeNamedQuery(step.query), step.arguments)
+			if err != nil {
+				return errors.Wrapf(err, "failed to bind %s", step.name)
+			}
+			batch.Queue(sqlx.Rebind(sqlx.DOLLAR, query), arguments...)
+		}
+
+		batchResults := stdlibConn.Conn().SendBatch(ctx, batch)
+		for index, step := range steps {
+			if step.queryType == qInsert {
+				var insertedID any
+				if err := batchResults.QueryRow().Scan(&insertedID); err != nil {
+					_ = batchResults.Close()
+					if errors.Is(err, pgx.ErrNoRows) {
+						return errors.Wrapf(noReturnedIDError(err), "failed to execute %s", step.name)
+					}
+					return errors.Wrapf(err, "failed to execute %s", step.name)
+				}
+				if insertedID == nil {
+					_ = batchResults.Close()
+					return noReturnedIDError(nil)
+				}
+				results[index] = insertedID
+				continue
+			}
+			if step.returning {
+				var returnedValue any
+				if err := batchResults.QueryRow().Scan(&returnedValue); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+					_ = batchResults.Close()
+					return errors.Wrapf(err, "failed to execute %s", step.name)
+				}
+				results[index] = returnedValue
+				continue
+			}
+
+			commandTag, err := batchResults.Exec()
+			if err != nil {
+				_ = batchResults.Close()
+				return errors.Wrapf(err, "failed to execute %s", step.name)
+			}
+			results[index] = commandTag.RowsAffected()
+		}
+
+		return errors.WithStack(batchResults.Close())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,143 @@
+//go:build pgx
+
+package postgres
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// benchmarkLatency is the delay the proxy adds in each direction, a 20ms round-trip.
+const benchmarkLatency = 10 * time.Millisecond
+
+// latencyProxy forwards connections to target, delaying every chunk by latency in each direction
+// to simulate a high-latency link. It returns the proxy address.
+func latencyProxy(tb testing.TB, target string, latency time.Duration) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listen: %v", err)
+	}
+	tb.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server, err := net.Dial("tcp", target)
+			if err != nil {
+				_ = client.Close()
+				continue
+			}
+			go delayCopy(server, client, latency)
+			go delayCopy(client, server, latency)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// delayCopy copies source to destination, sleeping latency before writing each chunk read.
+func delayCopy(destination, source net.Conn, latency time.Duration) {
+	defer destination.Close()
+	defer source.Close()
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := source.Read(buffer)
+		if n > 0 {
+			time.Sleep(latency)
+			if _, writeErr := destination.Write(buffer[:n]); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// proxiedClient connects to the DATABASE_URL server through a latency proxy with driverName,
+// and creates the table the benchmark inserts into.
+func proxiedClient(tb testing.TB, driverName string) Postgres {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		tb.Skip("DATABASE_URL is not set")
+	}
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		tb.Fatalf("parse DATABASE_URL: %v", err)
+	}
+	address := latencyProxy(tb, net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port))), benchmarkLatency)
+	host, port, _ := net.SplitHostPort(address)
+	portNumber, _ := strconv.Atoi(port)
+
+	db, err := New(
+		WithDriverName(driverName),
+		WithHost(host),
+		WithPort(portNumber),
+		WithUser(config.User),
+		WithPassword(config.Password),
+		WithDBName(config.Database),
+	)
+	if err != nil {
+		tb.Fatalf("New: %v", err)
+	}
+	tb.Cleanup(func() { _ = db.Close() })
+
+	ctx := context.Background()
+	if _, err = db.Batch(ctx, "CREATE TABLE IF NOT EXISTS pipeline_batch_bench (n int)", nil); err != nil {
+		tb.Fatalf("create table: %v", err)
+	}
+	return db
+}
+
+// twentyInserts builds a dependency-free pipeline of 20 inserts.
+func twentyInserts(db Postgres) Exec {
+	const query = "INSERT INTO pipeline_batch_bench (n) VALUES (:n)"
+	exec := db.Insert(query, "n", 0).NoReturn()
+	for i := 1; i < 20; i++ {
+		exec.Insert(query, "n", i).NoReturn()
+	}
+	return exec
+}
+
+// BenchmarkPipelineBatch runs a 20-insert pipeline over a link with a 20ms round-trip, sent as
+// one pgx batch and step by step on lib/pq. Run it with -tags pgx and DATABASE_URL set.
+func BenchmarkPipelineBatch(b *testing.B) {
+	for _, driverName := range []string{driverPgx, defaultDriverName} {
+		b.Run(driverName, func(b *testing.B) {
+			db := proxiedClient(b, driverName)
+			ctx := context.Background()
+			for b.Loop() {
+				if _, err := twentyInserts(db).ExecInTx(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineBatchInsertsEveryStep(t *testing.T) {
+	db := proxiedClient(t, driverPgx)
+	ctx := context.Background()
+	if _, err := db.Batch(ctx, "TRUNCATE pipeline_batch_bench", nil); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := twentyInserts(db).ExecInTx(ctx); err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+	var count int
+	if _, err := db.Select("SELECT count(*) FROM pipeline_batch_bench", &count).One(ctx); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 20 {
+		t.Errorf("batch inserted %d rows, want 20", count)
+	}
+}
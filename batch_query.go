@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// BatchResult is the result of one statement of a Batch.
+type BatchResult struct {
+	Query        string // Statement as split from the batch
+	RowsAffected int64  // Rows affected by the statement, or returned by a SELECT
+	Err          error  // Error of the statement, nil if it succeeded
+}
+
+// Batch runs the semicolon-separated statements of query in order, in one transaction, and
+// returns the result of each. Semicolons in literals, quoted identifiers and comments do not
+// split statements.
+//
+// args apply per statement: each statement binds only the :name parameters it references,
+// and a statement without any is sent as is, without arguments, so lib/pq uses the simple
+// query protocol for it. Every parameter of every statement is checked before anything runs.
+//
+// Like a multi-statement simple query, the batch stops at the first failing statement and
+// rolls back. The failing statement is the last result, and its error is also returned.
+//
+// Example:
+//
+//	results, err := db.Batch(ctx, `
+//		UPDATE accounts SET balance = balance - :amount WHERE id = :from;
+//		UPDATE accounts SET balance = balance + :amount WHERE id = :to;
+//	`, map[string]any{"amount": 10, "from": 1, "to": 2})
+func (postgresInstance *postgres) Batch(ctx context.Context, query string, args map[string]any) (results []BatchResult, err error) {
+	defer func() {
+		err = postgresInstance.wrapError(err)
+	}()
+
+	ctx, endSpan := postgresInstance.startSpan(ctx, "postgres.Batch", query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	statements := splitStatements(query)
+	if len(statements) == 0 {
+		return nil, errors.New("invalid operation: batch contains no statements")
+	}
+	for _, statement := range statements {
+		if err = checkArguments(statement, args); err != nil {
+			return nil, err
+		}
+		if postgresInstance.requireWhere && missesWhere(statement) {
+			return nil, errors.Wrapf(ErrMissingWhere, "query %q", statement)
+		}
+	}
+
+	var keyValuePairs []any
+	if args != nil {
+		keyValuePairs = []any{args}
+	}
+
+	results = make([]BatchResult, 0, len(statements))
+	err = postgresInstance.transact(ctx, postgresInstance.database, nil, func(t *transaction) error {
+		for _, statement := range statements {
+			var rowsAffected int64
+			err := t.run(ctx, statement, keyValuePairs, func(statement string, arguments map[string]any) (int64, error) {
+				var result sql.Result
+				var err error
+				if len(namedParameters(statement)) == 0 {
+					result, err = t.tx.ExecContext(ctx, statement)
+				} else {
+					result, err = t.tx.NamedExecContext(ctx, escapeNamedQuery(statement), arguments)
+				}
+				if err != nil {
+					return 0, errors.WithStack(err)
+				}
+				rowsAffected, err = result.RowsAffected()
+				return rowsAffected, errors.WithStack(err)
+			})
+			results = append(results, BatchResult{Query: statement, RowsAffected: rowsAffected, Err: err})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return results, err
+}
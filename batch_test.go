@@ -0,0 +1,28 @@
+package postgres
+
+import "testing"
+
+func TestPipelineBatchable(t *testing.T) {
+	db := newFakeDriver(nil).client(t)
+	const order = "INSERT INTO orders (item) VALUES (:item) RETURNING id"
+	const line = "INSERT INTO order_lines (order_id, item) VALUES (:order_id, :item) RETURNING id"
+
+	independent := db.Insert(order, "item", "book").(*execQuery)
+	independent.Insert(line, "order_id", 1, "item", "book").Insert(line, "order_id", 2, "item", "pen")
+	if !independent.pipeline.batchable(db.resultHook) {
+		t.Error("independent inserts are not batchable")
+	}
+
+	dependent := db.Insert(order, "item", "book").(*execQuery)
+	dependent.Insert(line, "order_id", dependent.FromResult(order), "item", "book")
+	if dependent.pipeline.batchable(db.resultHook) {
+		t.Error("an insert using FromResult is batchable")
+	}
+
+	selecting := db.Insert(order, "item", "book").(*execQuery)
+	var ids []int64
+	selecting.Select("SELECT id FROM orders", &ids)
+	if selecting.pipeline.batchable(db.resultHook) {
+		t.Error("a pipeline selecting into a destination is batchable")
+	}
+}
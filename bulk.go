@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxBindParameters is the maximum number of bind parameters PostgreSQL accepts in one statement.
+	maxBindParameters = 65535
+)
+
+// BulkInsert inserts all rows into table with a single multi-row INSERT statement
+// and returns the number of rows inserted.
+// Every row must have the same keys, which are used as column names in sorted order.
+// The total number of values must not exceed the PostgreSQL limit of 65535 bind parameters.
+func (postgresInstance *postgres) BulkInsert(ctx context.Context, table string, rows []map[string]any) (int64, error) {
+	query, arguments, err := buildBulkInsert(table, rows)
+	if err != nil {
+		return 0, postgresInstance.wrapError(err)
+	}
+
+	ctx, cancel := postgresInstance.withDefaultTimeout(ctx)
+	defer cancel()
+
+	started := time.Now()
+	rowsAffected, err := bulkInsert(ctx, postgresInstance.database, query, arguments)
+	postgresInstance.afterQuery(ctx, query, arguments, started, rowsAffected, err)
+
+	return rowsAffected, postgresInstance.wrapError(err)
+}
+
+// buildBulkInsert builds the multi-row INSERT statement and its named arguments.
+func buildBulkInsert(table string, rows []map[string]any) (string, map[string]any, error) {
+	if table == "" {
+		return "", nil, errors.New("bulk insert requires a table name")
+	}
+	if len(rows) == 0 {
+		return "", nil, errors.New("bulk insert requires at least one row")
+	}
+
+	columns := make([]string, 0, len(rows[0]))
+	for column := range rows[0] {
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return "", nil, errors.New("bulk insert requires at least one column")
+	}
+	sort.Strings(columns)
+
+	if len(rows)*len(columns) > maxBindParameters {
+		return "", nil, errors.Errorf("bulk insert of %d rows with %d columns exceeds the limit of %d bind parameters", len(rows), len(columns), maxBindParameters)
+	}
+
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = quoteIdentifier(column)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("INSERT INTO ")
+	builder.WriteString(quoteIdentifier(table))
+	builder.WriteString(" (")
+	builder.WriteString(strings.Join(quotedColumns, ", "))
+	builder.WriteString(") VALUES ")
+
+	arguments := make(map[string]any, len(rows)*len(columns))
+	placeholders := make([]string, len(columns))
+	for rowIndex, row := range rows {
+		if len(row) != len(columns) {
+			return "", nil, errors.Errorf("bulk insert row %d has %d columns, expected %d", rowIndex, len(row), len(columns))
+		}
+
+		for columnIndex, column := range columns {
+			value, ok := row[column]
+			if !ok {
+				return "", nil, errors.Errorf("bulk insert row %d is missing column %q", rowIndex, column)
+			}
+			key := fmt.Sprintf("p%d_%d", rowIndex, columnIndex)
+			placeholders[columnIndex] = ":" + key
+			arguments[key] = value
+		}
+
+		if rowIndex > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString("(")
+		builder.WriteString(strings.Join(placeholders, ", "))
+		builder.WriteString(")")
+	}
+
+	return builder.String(), arguments, nil
+}
+
+// bulkInsert executes the multi-row INSERT statement
+func bulkInsert(ctx context.Context, database *sqlx.DB, query string, arguments map[string]any) (int64, error) {
+	boundQuery, boundArguments, err := sqlx.Named(escapeNamedQuery(query), arguments)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	result, err := database.ExecContext(ctx, database.Rebind(boundQuery), boundArguments...)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return rowsAffected, nil
+}
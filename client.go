@@ -1,14 +1,23 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
+	"slices"
+
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
-	_ "github.com/newrelic/go-agent/v3/integrations/nrpq"
+	"github.com/pkg/errors"
 )
 
 // New creates a new postgres client
 func New(opts ...Option) (Postgres, error) {
+	return NewContext(context.Background(), opts...)
+}
+
+// NewContext creates a new postgres client, using ctx to connect and ping the database.
+// Cancelling ctx or reaching its deadline aborts connection establishment.
+func NewContext(ctx context.Context, opts ...Option) (Postgres, error) {
 	cfg := &config{}
 	for _, opt := range opts {
 		opt(cfg)
@@ -19,37 +28,146 @@ func New(opts ...Option) (Postgres, error) {
 		err    error
 	)
 
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+
 	if cfg.dsn == "" {
 		if err = cfg.BuildDsn(); err != nil {
 			return nil, err
 		}
+		// The files of a raw dsn are up to the driver, and the options are ignored with it
+		if err = cfg.checkSSLFiles(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.driverName == "" {
+		cfg.driverName = defaultDriverName
+	}
+	if cfg.resultHook == "" {
+		cfg.resultHook = qResult
+	}
+	if !slices.Contains(sql.Drivers(), cfg.driverName) {
+		return nil, errors.Errorf("sql driver %q is not registered, import its package to register it, e.g. _ \"github.com/lib/pq\" for postgres or _ \"github.com/jackc/pgx/v5/stdlib\" for pgx", cfg.driverName)
 	}
 
-	sqlxDB, err = sqlx.Connect(cfg.driverName, cfg.dsn)
+	sqlxDB, err = connect(ctx, cfg)
 	if err != nil {
-		return nil, err
+		return nil, errors.WithStack(err)
 	}
 
-	if err = sqlxDB.Ping(); err != nil {
+	if err = sqlxDB.PingContext(ctx); err != nil {
+		_ = sqlxDB.Close()
+		return nil, errors.WithStack(err)
+	}
+
+	replicas, err := connectReplicas(ctx, cfg)
+	if err != nil {
+		_ = sqlxDB.Close()
 		return nil, err
 	}
 
+	pq := newPostgres(sqlxDB, cfg)
+	pq.replicas = replicas
+
+	return pq, nil
+}
+
+// NewWithDB creates a new postgres client wrapping an existing pool, e.g. a shared pool
+// or a sqlmock database. It does not connect or ping, and connection options such as
+// the dsn, host and read replicas are ignored; the driver name is taken from db.
+// Closing the client closes db.
+//
+// Options that set up each new connection, WithSearchPath, WithTimeZone,
+// WithAfterConnect, WithPasswordFunc and WithConnMaxLifetimeJitter, cannot apply to a pool
+// opened elsewhere, so they fail with an error rather than being dropped. Like New, it
+// also returns the error of an invalid option.
+func NewWithDB(db *sqlx.DB, opts ...Option) (Postgres, error) {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	if db == nil {
+		return nil, errors.New("db is required")
+	}
+	if cfg.needsConnector() {
+		return nil, errors.New("connection options such as WithSearchPath or WithPasswordFunc need New, which opens the connections")
+	}
+
+	cfg.driverName = db.DriverName()
+	if cfg.resultHook == "" {
+		cfg.resultHook = qResult
+	}
+
+	return newPostgres(db, cfg), nil
+}
+
+// newPostgres builds the client around database and applies the configured pool limits and field mapper.
+func newPostgres(database *sqlx.DB, cfg *config) *postgres {
 	pq := &postgres{
-		database: sqlxDB,
+		database:           database,
+		driverName:         cfg.driverName,
+		withoutStackTraces: cfg.withoutStackTraces,
+		logger:             cfg.logger,
+		observer:           cfg.observer,
+		sampleRate:         cfg.sampleRate,
+		sampling:           cfg.sampling,
+		tracer:             cfg.tracer,
+		contextFieldsFunc:  cfg.contextFieldsFunc,
+		redactedKeys:       cfg.redactedKeys,
+		resultHook:         cfg.resultHook,
+		fieldMapper:        cfg.fieldMapper,
+		requireWhere:       cfg.requireWhere,
+		defaultTimeout:     cfg.defaultTimeout,
+	}
+	if cfg.statementCacheSize > 0 {
+		pq.statements = newStatementCache(cfg.statementCacheSize)
 	}
 
+	configurePool(pq.database, cfg)
+	pq.logWarnings(context.Background(), cfg.warnings)
+
+	return pq
+}
+
+// configurePool applies the configured pool limits and field mapper to database.
+func configurePool(database *sqlx.DB, cfg *config) {
+	if cfg.fieldMapper != nil {
+		database.MapperFunc(cfg.fieldMapper)
+	}
 	if cfg.maxOpenConns > 0 {
-		pq.database.SetMaxOpenConns(cfg.maxOpenConns)
+		database.SetMaxOpenConns(cfg.maxOpenConns)
 	}
 	if cfg.maxIdleConns > 0 {
-		pq.database.SetMaxIdleConns(cfg.maxIdleConns)
+		database.SetMaxIdleConns(cfg.maxIdleConns)
 	}
 	if cfg.connMaxLifetime > 0 {
-		pq.database.SetConnMaxLifetime(cfg.connMaxLifetime)
+		// With jitter the connector expires each connection, so the pool limit is only the upper bound
+		database.SetConnMaxLifetime(cfg.connMaxLifetime + cfg.connMaxLifetimeJitter)
 	}
 	if cfg.connMaxIdleTime > 0 {
-		pq.database.SetConnMaxIdleTime(cfg.connMaxIdleTime)
+		database.SetConnMaxIdleTime(cfg.connMaxIdleTime)
 	}
+}
 
-	return pq, nil
+// connect opens the database, going through the client connector when an option needs it.
+func connect(ctx context.Context, cfg *config) (*sqlx.DB, error) {
+	if !cfg.needsConnector() {
+		return sqlx.ConnectContext(ctx, cfg.driverName, cfg.dsn)
+	}
+
+	connector, err := newConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlxDB := sqlx.NewDb(sql.OpenDB(connector), cfg.driverName)
+	if err = sqlxDB.PingContext(ctx); err != nil {
+		_ = sqlxDB.Close()
+		return nil, err
+	}
+	return sqlxDB, nil
 }
@@ -0,0 +1,33 @@
+//go:build pgx
+
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// TestNewWithPgxDriver connects through pgx's stdlib driver to the server of DATABASE_URL.
+func TestNewWithPgxDriver(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	db, err := New(WithDsn(dsn), WithDriverName(driverPgx))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	var one int
+	if _, err = db.Select("SELECT 1 AS one", &one).One(context.Background()); err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if one != 1 {
+		t.Errorf("SELECT 1 returned %d", one)
+	}
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewUnregisteredDriver(t *testing.T) {
+	_, err := New(WithDsn("host=localhost"), WithDriverName("not-registered"))
+	if err == nil || !strings.Contains(err.Error(), `sql driver "not-registered" is not registered`) {
+		t.Errorf("New() = %v, want an unregistered driver error", err)
+	}
+}
+
+func TestDefaultDriverName(t *testing.T) {
+	cfg := &config{}
+	for _, opt := range []Option{WithHost("localhost"), WithUser("app"), WithPassword("secret"), WithDBName("app")} {
+		opt(cfg)
+	}
+	if err := cfg.BuildDsn(); err != nil {
+		t.Fatalf("BuildDsn: %v", err)
+	}
+	if cfg.driverName != "postgres" {
+		t.Errorf("driver name = %q, want postgres", cfg.driverName)
+	}
+}
+
+func TestNewRelicOnlyWithBuildTag(t *testing.T) {
+	if newRelicSupported {
+		t.Skip("built with the newrelic tag")
+	}
+	if slices.Contains(sql.Drivers(), newRelicDriver) {
+		t.Errorf("the %s driver is registered without the newrelic build tag", newRelicDriver)
+	}
+	_, err := New(WithDsn("host=localhost"), WithNewRelic())
+	if err == nil || !strings.Contains(err.Error(), "newrelic build tag") {
+		t.Errorf("New(WithNewRelic()) = %v, want a build tag error", err)
+	}
+}
+
+func TestNewWithDBRejectsConnectorOptions(t *testing.T) {
+	tests := map[string]Option{
+		"search path":     WithSearchPath("app"),
+		"time zone":       WithTimeZone("UTC"),
+		"after connect":   WithAfterConnect(func(context.Context, *sql.Conn) error { return nil }),
+		"password func":   WithPasswordFunc(func(context.Context) (string, error) { return "", nil }),
+		"lifetime jitter": WithConnMaxLifetimeJitter(time.Minute),
+	}
+	for name, option := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewWithDB(newFakeDriver(nil).db(t), WithConnMaxLifetime(time.Hour), option)
+			if err == nil {
+				t.Error("NewWithDB() succeeded, want an error")
+			}
+		})
+	}
+
+	if _, err := NewWithDB(newFakeDriver(nil).db(t), WithConnMaxLifetime(time.Hour)); err != nil {
+		t.Errorf("NewWithDB() with pool options = %v", err)
+	}
+}
+
+func TestNewChecksSSLFilesOfBuiltDsnOnly(t *testing.T) {
+	newFakeDriver(nil).register(t, "ssl-check")
+	missing := WithSSLRootCert(t.TempDir() + "/missing.pem")
+
+	_, err := New(WithDriverName(fakeDriverName), WithHost("ssl-check"), WithUser("app"), WithPassword("secret"), WithDBName("app"), missing)
+	if err == nil || !strings.Contains(err.Error(), "invalid ssl root certificate file") {
+		t.Errorf("New() with a built dsn = %v, want a missing file error", err)
+	}
+
+	db, err := New(WithDriverName(fakeDriverName), WithDsn("host=ssl-check dbname=app"), missing)
+	if err != nil {
+		t.Fatalf("New() with WithDsn = %v, want the ignored file not checked", err)
+	}
+	_ = db.Close()
+}
+
+// warningRecorder is a WarningLogger that records the configuration warnings it receives.
+type warningRecorder struct {
+	warnings []string
+}
+
+func (*warningRecorder) LogQuery(context.Context, string, map[string]any, time.Duration, error) {}
+
+func (w *warningRecorder) LogWarning(_ context.Context, message string) {
+	w.warnings = append(w.warnings, message)
+}
+
+func TestLastMaxOpenConnsOptionWins(t *testing.T) {
+	tests := map[string]struct {
+		opts []Option
+		want int
+	}{
+		"WithConnMax last":      {[]Option{WithMaxOpenConns(5), WithConnMax(3)}, 3},
+		"WithMaxOpenConns last": {[]Option{WithConnMax(3), WithMaxOpenConns(5)}, 5},
+	}
+	for name, test := range tests {
+		logger := &warningRecorder{}
+		db := newFakeDriver(nil).client(t, append(test.opts, WithLogger(logger))...)
+		if got := db.database.Stats().MaxOpenConnections; got != test.want {
+			t.Errorf("%s: MaxOpenConnections = %d, want %d", name, got, test.want)
+		}
+		if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "WithConnMax is deprecated") {
+			t.Errorf("%s: warnings = %q, want one deprecation of WithConnMax", name, logger.warnings)
+		}
+	}
+
+	logger := &warningRecorder{}
+	newFakeDriver(nil).client(t, WithMaxOpenConns(5), WithLogger(logger))
+	if len(logger.warnings) != 0 {
+		t.Errorf("warnings without WithConnMax = %q, want none", logger.warnings)
+	}
+}
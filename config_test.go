@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuildDsnEscapesValues(t *testing.T) {
+	cfg := &config{}
+	for _, opt := range []Option{
+		WithHost("db host"),
+		WithUser(`o'brien`),
+		WithPassword(`p\w d`),
+		WithDBName("app sslmode=disable"),
+		WithApplicationName("api"),
+	} {
+		opt(cfg)
+	}
+	if err := cfg.BuildDsn(); err != nil {
+		t.Fatalf("BuildDsn: %v", err)
+	}
+
+	want := `host='db host' port=5432 user='o\'brien' password='p\\w d' dbname='app sslmode=disable' sslmode=disable application_name=api`
+	if cfg.dsn != want {
+		t.Errorf("BuildDsn() = %s, want %s", cfg.dsn, want)
+	}
+}
+
+func TestEscapeDsnValue(t *testing.T) {
+	tests := map[string]string{
+		"plain":   "plain",
+		"":        "''",
+		"a b":     "'a b'",
+		`it's`:    `'it\'s'`,
+		`back\sl`: `'back\\sl'`,
+	}
+	for value, want := range tests {
+		if got := escapeDsnValue(value); got != want {
+			t.Errorf("escapeDsnValue(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestBuildDsnAddsSSLFiles(t *testing.T) {
+	cfg := &config{}
+	for _, opt := range []Option{
+		WithHost("db"),
+		WithUser("app"),
+		WithPassword("secret"),
+		WithDBName("app"),
+		WithSSLMode("verify-full"),
+		WithSSLRootCert("/etc/ssl/root.pem"),
+		WithSSLCert("/etc/ssl/client cert.pem"),
+		WithSSLKey("/etc/ssl/client.key"),
+	} {
+		opt(cfg)
+	}
+	if err := cfg.BuildDsn(); err != nil {
+		t.Fatalf("BuildDsn: %v", err)
+	}
+
+	want := `host=db port=5432 user=app password=secret dbname=app sslmode=verify-full sslrootcert=/etc/ssl/root.pem sslcert='/etc/ssl/client cert.pem' sslkey=/etc/ssl/client.key`
+	if cfg.dsn != want {
+		t.Errorf("BuildDsn() = %s, want %s", cfg.dsn, want)
+	}
+}
+
+func TestCheckSSLFiles(t *testing.T) {
+	directory := t.TempDir()
+	existing := directory + "/root.pem"
+	if err := os.WriteFile(existing, []byte("certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config{sslRootCert: existing}
+	if err := cfg.checkSSLFiles(); err != nil {
+		t.Errorf("checkSSLFiles() with an existing file = %v", err)
+	}
+	cfg.sslKey = directory + "/missing.key"
+	if err := cfg.checkSSLFiles(); err == nil || !strings.Contains(err.Error(), "invalid ssl key file") {
+		t.Errorf("checkSSLFiles() with a missing key = %v, want an invalid ssl key file error", err)
+	}
+}
@@ -0,0 +1,305 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// connector wraps the driver connector so the client can manage each physical connection.
+type connector struct {
+	base     driver.Connector
+	lifetime time.Duration
+	jitter   time.Duration
+	setup    []string // Statements run on every new connection before it is handed to the pool
+	hooks    []func(ctx context.Context, conn *sql.Conn) error
+}
+
+// dsnConnector adapts a driver without driver.DriverContext to driver.Connector.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+// passwordConnector opens every connection with the dsn completed by a password fetched
+// for that connection.
+type passwordConnector struct {
+	dsn          string
+	driver       driver.Driver
+	passwordFunc func(ctx context.Context) (string, error)
+}
+
+// conn is a physical connection created by connector.
+// It forwards every optional database/sql driver interface to the wrapped connection.
+type conn struct {
+	driver.Conn
+	expiresAt time.Time
+}
+
+// newConnector creates a connector for the registered driver and dsn.
+func newConnector(cfg *config) (*connector, error) {
+	database, err := sql.Open(cfg.driverName, cfg.dsn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	driverInstance := database.Driver()
+	_ = database.Close()
+
+	var base driver.Connector = &dsnConnector{dsn: cfg.dsn, driver: driverInstance}
+	if cfg.passwordFunc != nil {
+		base = &passwordConnector{dsn: cfg.dsn, driver: driverInstance, passwordFunc: cfg.passwordFunc}
+	} else if driverContext, ok := driverInstance.(driver.DriverContext); ok {
+		if base, err = driverContext.OpenConnector(cfg.dsn); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return &connector{
+		base:     base,
+		lifetime: cfg.connMaxLifetime,
+		jitter:   cfg.connMaxLifetimeJitter,
+		setup:    cfg.sessionStatements(),
+		hooks:    cfg.afterConnect,
+	}, nil
+}
+
+// Connect opens a new physical connection.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	driverConn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statement := range c.setup {
+		if err = execDriverConn(ctx, driverConn, statement); err != nil {
+			_ = driverConn.Close()
+			return nil, errors.Wrapf(err, "failed to set up connection with %q", statement)
+		}
+	}
+
+	wrapped := &conn{Conn: driverConn}
+	if c.lifetime > 0 && c.jitter > 0 {
+		wrapped.expiresAt = time.Now().Add(c.lifetime + rand.N(c.jitter))
+	}
+
+	for _, hook := range c.hooks {
+		if err = runAfterConnect(ctx, c.Driver(), wrapped, hook); err != nil {
+			_ = driverConn.Close()
+			return nil, errors.Wrap(err, "after connect hook failed")
+		}
+	}
+	return wrapped, nil
+}
+
+// runAfterConnect calls hook with a *sql.Conn backed by the new connection driverConn.
+// The connection is lent through a single-connection pool that never closes it.
+func runAfterConnect(ctx context.Context, driverInstance driver.Driver, driverConn *conn, hook func(ctx context.Context, conn *sql.Conn) error) error {
+	database := sql.OpenDB(&borrowedConnector{conn: borrowedConn{conn: driverConn}, driver: driverInstance})
+	defer database.Close()
+	database.SetMaxOpenConns(1)
+
+	sqlConn, err := database.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sqlConn.Close()
+
+	return hook(ctx, sqlConn)
+}
+
+// borrowedConnector hands out a single existing connection.
+type borrowedConnector struct {
+	conn   borrowedConn
+	driver driver.Driver
+}
+
+// Connect returns the borrowed connection.
+func (c *borrowedConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+
+// Driver returns the underlying driver.
+func (c *borrowedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// borrowedConn is a connection lent to an after connect hook. Closing it leaves the
+// connection open for the pool it was created for.
+type borrowedConn struct {
+	*conn
+}
+
+func (c borrowedConn) Close() error {
+	return nil
+}
+
+// execDriverConn runs a statement without arguments directly on a driver connection.
+func execDriverConn(ctx context.Context, driverConn driver.Conn, statement string) error {
+	if execer, ok := driverConn.(driver.ExecerContext); ok {
+		if _, err := execer.ExecContext(ctx, statement, nil); err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	var (
+		preparedStatement driver.Stmt
+		err               error
+	)
+	if preparer, ok := driverConn.(driver.ConnPrepareContext); ok {
+		preparedStatement, err = preparer.PrepareContext(ctx, statement)
+	} else {
+		preparedStatement, err = driverConn.Prepare(statement)
+	}
+	if err != nil {
+		return err
+	}
+	defer preparedStatement.Close()
+
+	if execer, ok := preparedStatement.(driver.StmtExecContext); ok {
+		_, err = execer.ExecContext(ctx, nil)
+		return err
+	}
+	_, err = preparedStatement.Exec(nil)
+	return err
+}
+
+// Driver returns the underlying driver.
+func (c *connector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+// Connect opens a new connection using the dsn.
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver returns the underlying driver.
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Connect fetches a password and opens a new connection using the dsn with that password.
+func (c *passwordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.passwordFunc(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get password")
+	}
+	dsn, err := dsnWithPassword(c.dsn, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if driverContext, ok := c.driver.(driver.DriverContext); ok {
+		base, err := driverContext.OpenConnector(dsn)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return base.Connect(ctx)
+	}
+	return c.driver.Open(dsn)
+}
+
+// Driver returns the underlying driver.
+func (c *passwordConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnWithPassword returns dsn with its password set to password. A key=value dsn gets a
+// trailing password key, which overrides an earlier one; a URL dsn gets the password in its user info.
+func dsnWithPassword(dsn, password string) (string, error) {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " password=" + escapeDsnValue(password), nil
+	}
+
+	parsedURL, err := url.Parse(dsn)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid dsn")
+	}
+	username := ""
+	if parsedURL.User != nil {
+		username = parsedURL.User.Username()
+	}
+	parsedURL.User = url.UserPassword(username, password)
+	return parsedURL.String(), nil
+}
+
+// Unwrap returns the driver connection, for callers that need the concrete driver type.
+func (c *conn) Unwrap() driver.Conn {
+	return c.Conn
+}
+
+// expired returns true once the connection has outlived its jittered lifetime.
+func (c *conn) expired() bool {
+	return !c.expiresAt.IsZero() && time.Now().After(c.expiresAt)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("driver does not support non-default transaction options")
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.expired() {
+		return driver.ErrBadConn
+	}
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if c.expired() {
+		return false
+	}
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
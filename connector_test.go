@@ -0,0 +1,250 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// schemaServer emulates how a server resolves names against the search path. It keeps the
+// tables created with CREATE TABLE schema.name and the settings of SET name TO value,
+// SET TIME ZONE, set_config and SHOW,
+// and answers SELECT schema FROM name with the first schema of the search path holding name.
+type schemaServer struct {
+	mu     sync.Mutex
+	tables map[string]bool // Qualified as schema.name
+}
+
+func newSchemaServer() *schemaServer {
+	return &schemaServer{tables: map[string]bool{}}
+}
+
+func (s *schemaServer) handle(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	session := fakeSessionFrom(ctx)
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE "):
+		s.mu.Lock()
+		s.tables[strings.TrimPrefix(query, "CREATE TABLE ")] = true
+		s.mu.Unlock()
+	case strings.HasPrefix(query, "SET TIME ZONE "):
+		session.settings["TimeZone"] = strings.Trim(strings.TrimPrefix(query, "SET TIME ZONE "), "'")
+	case strings.HasPrefix(query, "SET "):
+		name, value, _ := strings.Cut(strings.TrimPrefix(query, "SET "), " TO ")
+		session.settings[name] = value
+	case strings.HasPrefix(query, "SELECT set_config("):
+		session.local[args[0].Value.(string)] = args[1].Value.(string)
+		return fakeResult{columns: []string{"set_config"}, rows: [][]driver.Value{{args[1].Value}}}, nil
+	case strings.HasPrefix(query, "SHOW "):
+		return fakeResult{columns: []string{"setting"}, rows: [][]driver.Value{{session.setting(strings.TrimPrefix(query, "SHOW "))}}}, nil
+	case strings.HasPrefix(query, "SELECT schema FROM "):
+		name := strings.TrimPrefix(query, "SELECT schema FROM ")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, schema := range strings.Split(session.setting("search_path"), ", ") {
+			schema = strings.ReplaceAll(strings.Trim(schema, `"`), `""`, `"`)
+			if s.tables[schema+"."+name] {
+				return fakeResult{columns: []string{"schema"}, rows: [][]driver.Value{{schema}}}, nil
+			}
+		}
+		return fakeResult{}, fmt.Errorf("relation %q does not exist", name)
+	}
+	return fakeResult{}, nil
+}
+
+// newSessionClient creates a client through New on server, registered for host, with opts.
+func newSessionClient(t *testing.T, server *schemaServer, host string, opts ...Option) *postgres {
+	t.Helper()
+	newFakeDriver(server.handle).register(t, host)
+	opts = append([]Option{WithDriverName(fakeDriverName), WithHost(host), WithUser("app"), WithPassword("secret"), WithDBName("app")}, opts...)
+	db, err := New(opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db.(*postgres)
+}
+
+func TestSearchPathResolvesUnqualifiedNames(t *testing.T) {
+	server := newSchemaServer()
+	db := newSessionClient(t, server, "search-path", WithSearchPath("tenant_a", "public"))
+	ctx := context.Background()
+	for _, table := range []string{"tenant_a.widgets", "public.gadgets", "tenant_b.sprockets"} {
+		if _, err := db.DB().ExecContext(ctx, "CREATE TABLE "+table); err != nil {
+			t.Fatalf("CREATE TABLE %s: %v", table, err)
+		}
+	}
+	// Without idle connections every query runs on a new connection, which must be set up too
+	db.database.SetMaxIdleConns(-1)
+
+	for table, want := range map[string]string{"widgets": "tenant_a", "gadgets": "public"} {
+		var schema string
+		if err := db.Scalar(ctx, "SELECT schema FROM "+table, &schema); err != nil || schema != want {
+			t.Errorf("SELECT FROM %s resolved to %q, %v, want %s", table, schema, err, want)
+		}
+	}
+	var schema string
+	if err := db.Scalar(ctx, "SELECT schema FROM sprockets", &schema); err == nil {
+		t.Errorf("SELECT FROM sprockets resolved to %q, want it outside the search path", schema)
+	}
+}
+
+func TestSearchPathQuotesSchemas(t *testing.T) {
+	cfg := &config{}
+	WithSearchPath("tenant_a", `odd"name`, "$user")(cfg)
+	want := []string{`SET search_path TO "tenant_a", "odd""name", "$user"`}
+	if got := cfg.sessionStatements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sessionStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestPasswordFuncRunsForEveryConnection(t *testing.T) {
+	fake := newFakeDriver(selectOne)
+	fake.register(t, "password-rotation")
+
+	var tokens atomic.Int64
+	db, err := New(
+		WithDriverName(fakeDriverName),
+		WithHost("password-rotation"),
+		WithUser("app"),
+		WithDBName("app"),
+		WithPasswordFunc(func(context.Context) (string, error) {
+			return fmt.Sprintf("token-%d", tokens.Add(1)), nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+	// Without idle connections every query opens a new physical connection
+	db.(*postgres).database.SetMaxIdleConns(-1)
+
+	for range 3 {
+		var id int64
+		if _, err = db.Select("SELECT id FROM t", &id).One(context.Background()); err != nil {
+			t.Fatalf("One: %v", err)
+		}
+	}
+
+	dsns := fake.openedDsns()
+	if len(dsns) < 4 {
+		t.Fatalf("opened %d connections, want the ping's and one per query", len(dsns))
+	}
+	for i, dsn := range dsns {
+		if want := fmt.Sprintf(" password=token-%d", i+1); !strings.HasSuffix(dsn, want) {
+			t.Errorf("connection %d opened with %q, want the password %q", i, dsn, want)
+		}
+	}
+}
+
+func TestPasswordFuncErrorFailsConnection(t *testing.T) {
+	newFakeDriver(nil).register(t, "password-error")
+	failure := errors.New("token service unavailable")
+
+	_, err := New(
+		WithDriverName(fakeDriverName),
+		WithHost("password-error"),
+		WithUser("app"),
+		WithDBName("app"),
+		WithPasswordFunc(func(context.Context) (string, error) { return "", failure }),
+	)
+	if !errors.Is(err, failure) {
+		t.Errorf("New() = %v, want the password func error", err)
+	}
+}
+
+func TestDsnWithPassword(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"host=db user=app", "host=db user=app password='it s'"},
+		{"postgres://app@db:5432/app?sslmode=disable", "postgres://app:it%20s@db:5432/app?sslmode=disable"},
+		{"postgresql://app:old@db/app", "postgresql://app:it%20s@db/app"},
+	}
+	for _, test := range tests {
+		got, err := dsnWithPassword(test.dsn, "it s")
+		if err != nil || got != test.want {
+			t.Errorf("dsnWithPassword(%q) = %q, %v, want %q", test.dsn, got, err, test.want)
+		}
+	}
+}
+
+func TestTimeZoneOnFreshConnections(t *testing.T) {
+	db := newSessionClient(t, newSchemaServer(), "time-zone", WithTimeZone("Asia/Jakarta"))
+	db.database.SetMaxIdleConns(-1)
+
+	for range 2 {
+		var timeZone string
+		if err := db.Scalar(context.Background(), "SHOW TimeZone", &timeZone); err != nil || timeZone != "Asia/Jakarta" {
+			t.Errorf("SHOW TimeZone = %q, %v, want Asia/Jakarta", timeZone, err)
+		}
+	}
+}
+
+func TestTimeZoneRejectsInvalidNames(t *testing.T) {
+	for _, timeZone := range []string{"", "UTC'; DROP TABLE users; --", "Europe/Berlin UTC"} {
+		if _, err := New(WithDsn("host=localhost"), WithTimeZone(timeZone)); err == nil || !strings.Contains(err.Error(), "invalid time zone") {
+			t.Errorf("WithTimeZone(%q) = %v, want an invalid time zone error", timeZone, err)
+		}
+	}
+}
+
+func TestAfterConnectRunsOncePerConnection(t *testing.T) {
+	var calls atomic.Int64
+	db := newSessionClient(t, newSchemaServer(), "after-connect", WithAfterConnect(func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, fmt.Sprintf("SET app.connection TO %d", calls.Add(1)))
+		return err
+	}))
+	ctx := context.Background()
+
+	// The ping of New opened the first connection; hold three at once to grow the pool
+	var conns []*sql.Conn
+	for range 3 {
+		conn, err := db.DB().Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("hook ran %d times for 3 connections, want 3", got)
+	}
+
+	// The setting of each connection persists for every later query on it
+	seen := map[string]bool{}
+	for _, conn := range conns {
+		for range 2 {
+			var value string
+			if err := conn.QueryRowContext(ctx, "SHOW app.connection").Scan(&value); err != nil {
+				t.Fatalf("SHOW: %v", err)
+			}
+			seen[value] = true
+		}
+		_ = conn.Close()
+	}
+	if len(seen) != 3 {
+		t.Errorf("connections showed settings %v, want one distinct setting each", seen)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("hook ran %d times after reusing the connections, want 3", got)
+	}
+}
+
+func TestAfterConnectErrorFailsConnection(t *testing.T) {
+	newFakeDriver(nil).register(t, "after-connect-error")
+	failure := errors.New("tenant lookup failed")
+	_, err := New(
+		WithDriverName(fakeDriverName), WithHost("after-connect-error"), WithUser("app"), WithPassword("secret"), WithDBName("app"),
+		WithAfterConnect(func(context.Context, *sql.Conn) error { return failure }),
+	)
+	if !errors.Is(err, failure) {
+		t.Errorf("New() = %v, want the hook error", err)
+	}
+}
@@ -0,0 +1,413 @@
+package postgres
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CopyFormat is the output format used by CopyTo.
+type CopyFormat string
+
+const (
+	// CopyFormatText is the PostgreSQL COPY text format: tab separated, \N for NULL.
+	CopyFormatText CopyFormat = "text"
+	// CopyFormatCSV is the PostgreSQL COPY CSV format: comma separated, empty for NULL.
+	CopyFormatCSV CopyFormat = "csv"
+)
+
+var (
+	copyTextEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+)
+
+type (
+	// CopyOption is a function that configures a copy operation.
+	CopyOption func(*copyConfig)
+
+	// copyConfig is the configuration for a copy operation.
+	copyConfig struct {
+		format CopyFormat
+		header bool
+	}
+)
+
+// WithCopyFormat sets the copy format.
+// format is either CopyFormatText (default) or CopyFormatCSV.
+func WithCopyFormat(format CopyFormat) CopyOption {
+	return func(c *copyConfig) {
+		c.format = format
+	}
+}
+
+// WithCopyHeader writes the column names as the first line.
+// It only applies to CopyFormatCSV, matching COPY ... WITH (HEADER).
+func WithCopyHeader() CopyOption {
+	return func(c *copyConfig) {
+		c.header = true
+	}
+}
+
+// CopyTo streams the result of query to w and returns the number of rows written.
+//
+// Built with the "pgx" build tag and connected with the pgx driver, it runs
+// COPY (<query>) TO STDOUT on the server, which encodes and streams the rows itself.
+// lib/pq does not implement COPY TO STDOUT, so otherwise rows are read with a regular query
+// and encoded client-side at the speed of an ordinary select, in the layout of COPY output.
+// Values are formatted by column type like the server does, e.g. bytea as \x hex and floats
+// without an exponent below 1e15; types without a special case are printed as the driver
+// scans them. Rows are written as they arrive, so memory usage stays bounded for large result sets.
+func (postgresInstance *postgres) CopyTo(ctx context.Context, w io.Writer, query string, opts ...CopyOption) (int64, error) {
+	ctx, cancel := postgresInstance.withDefaultTimeout(ctx)
+	defer cancel()
+
+	started := time.Now()
+	count, err := copyTo(ctx, postgresInstance, w, query, opts...)
+	postgresInstance.afterQuery(ctx, query, nil, started, count, err)
+
+	return count, postgresInstance.wrapError(err)
+}
+
+// copyTo runs the query and encodes every row into w.
+func copyTo(ctx context.Context, postgresInstance *postgres, w io.Writer, query string, opts ...CopyOption) (int64, error) {
+	cfg := &copyConfig{format: CopyFormatText}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.format != CopyFormatText && cfg.format != CopyFormatCSV {
+		return 0, fmt.Errorf("invalid copy format %q: expected %q or %q", cfg.format, CopyFormatText, CopyFormatCSV)
+	}
+
+	if copyToSupported && postgresInstance.driverName == driverPgx {
+		return copyToServer(ctx, postgresInstance.database, w, copyToStatement(query, cfg))
+	}
+
+	rows, err := postgresInstance.database.QueryxContext(ctx, query)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	typeNames := make([]string, len(columnTypes))
+	for i, columnType := range columnTypes {
+		typeNames[i] = strings.ToUpper(columnType.DatabaseTypeName())
+	}
+
+	isCSV := cfg.format == CopyFormatCSV
+	if isCSV && cfg.header {
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = quoteCopyCSV(column, false, len(columns) == 1)
+		}
+		if _, err = io.WriteString(w, strings.Join(header, ",")+"\n"); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+
+	var count int64
+	record := make([]string, len(columns))
+	for rows.Next() {
+		values, err := rows.SliceScan()
+		if err != nil {
+			return count, errors.WithStack(err)
+		}
+
+		if isCSV {
+			for i, value := range values {
+				record[i] = quoteCopyCSV(formatCopyValue(value, typeNames[i], ""), value == nil, len(columns) == 1)
+			}
+			if _, err = io.WriteString(w, strings.Join(record, ",")+"\n"); err != nil {
+				return count, errors.WithStack(err)
+			}
+		} else {
+			for i, value := range values {
+				record[i] = escapeCopyText(formatCopyValue(value, typeNames[i], `\N`), value == nil)
+			}
+			if _, err = io.WriteString(w, strings.Join(record, "\t")+"\n"); err != nil {
+				return count, errors.WithStack(err)
+			}
+		}
+		count++
+	}
+	if err = rows.Err(); err != nil {
+		return count, errors.WithStack(err)
+	}
+
+	return count, nil
+}
+
+// copyToStatement returns the COPY TO STDOUT statement exporting query in the configured format.
+func copyToStatement(query string, cfg *copyConfig) string {
+	statement := "COPY (" + strings.TrimSuffix(strings.TrimSpace(query), ";") + ") TO STDOUT"
+	if cfg.format == CopyFormatCSV {
+		if cfg.header {
+			return statement + " WITH (FORMAT csv, HEADER)"
+		}
+		return statement + " WITH (FORMAT csv)"
+	}
+	return statement
+}
+
+// formatCopyValue renders a scanned value the way PostgreSQL prints it in COPY output.
+// typeName is the database type name of the column, e.g. BYTEA or DATE, which tells apart
+// values the driver scans into the same Go type.
+func formatCopyValue(value any, typeName, null string) string {
+	switch v := value.(type) {
+	case nil:
+		return null
+	case []byte:
+		if typeName == "BYTEA" {
+			return `\x` + hex.EncodeToString(v)
+		}
+		return string(v)
+	case string:
+		return v
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		if typeName == "FLOAT4" {
+			return formatCopyFloat(v, 32)
+		}
+		return formatCopyFloat(v, 64)
+	case float32:
+		return formatCopyFloat(float64(v), 32)
+	case time.Time:
+		switch typeName {
+		case "DATE":
+			return v.Format("2006-01-02")
+		case "TIMESTAMP":
+			return v.Format("2006-01-02 15:04:05.999999")
+		case "TIME":
+			return v.Format("15:04:05.999999")
+		case "TIMETZ":
+			return v.Format("15:04:05.999999") + formatCopyOffset(v)
+		default:
+			return v.Format("2006-01-02 15:04:05.999999") + formatCopyOffset(v)
+		}
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// formatCopyFloat formats a float like PostgreSQL: the shortest representation that reads back
+// to the same value, in exponential notation only for exponents below -4 or from 15 digits
+// up for float8, 6 for float4.
+func formatCopyFloat(value float64, bitSize int) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	}
+
+	exponential := strconv.FormatFloat(value, 'e', -1, bitSize)
+	exponent, err := strconv.Atoi(exponential[strings.IndexByte(exponential, 'e')+1:])
+	maxExponent := 15
+	if bitSize == 32 {
+		maxExponent = 6
+	}
+	if err != nil || exponent < -4 || exponent >= maxExponent {
+		return exponential
+	}
+	return strconv.FormatFloat(value, 'f', -1, bitSize)
+}
+
+// formatCopyOffset formats the UTC offset of t like PostgreSQL: +HH, with :MM and :SS
+// only when they are not zero.
+func formatCopyOffset(t time.Time) string {
+	_, offset := t.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	formatted := fmt.Sprintf("%s%02d", sign, offset/3600)
+	if minutes, seconds := offset%3600/60, offset%60; minutes != 0 || seconds != 0 {
+		formatted += fmt.Sprintf(":%02d", minutes)
+		if seconds != 0 {
+			formatted += fmt.Sprintf(":%02d", seconds)
+		}
+	}
+	return formatted
+}
+
+// escapeCopyText escapes backslashes and control characters for the COPY text format.
+func escapeCopyText(value string, isNull bool) string {
+	if isNull {
+		return value
+	}
+	return copyTextEscaper.Replace(value)
+}
+
+// quoteCopyCSV quotes a value for the COPY CSV format like the server does: NULL is an empty
+// field, and a value is quoted if it is empty, so it differs from NULL, or contains a comma,
+// quote or line break. A lone \. is quoted in single-column output, where it would read as
+// the end-of-data marker. Quotes are escaped by doubling them.
+func quoteCopyCSV(value string, isNull, singleColumn bool) string {
+	if isNull {
+		return value
+	}
+	if value == "" || strings.ContainsAny(value, ",\"\r\n") || (singleColumn && value == `\.`) {
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	}
+	return value
+}
+
+// CopyFrom loads rows into table with COPY FROM STDIN and returns the number of rows copied.
+// table may be schema-qualified, e.g. public.users, and is read like an identifier in SQL:
+// unquoted names are folded to lower case, and double-quoted ones such as "Sales"."Q1.2024"
+// are taken as is, dots included. Each row holds one value per column.
+//
+// The copy runs in its own transaction and is rolled back if any row fails.
+// It bypasses the named-parameter path entirely and requires the lib/pq driver, which runs
+// the COPY FROM STDIN statement as a copy; the package builds it without importing lib/pq.
+func (postgresInstance *postgres) CopyFrom(ctx context.Context, table string, columns []string, rows [][]any) (int64, error) {
+	ctx, cancel := postgresInstance.withDefaultTimeout(ctx)
+	defer cancel()
+
+	started := time.Now()
+	count, err := copyFrom(ctx, postgresInstance, table, columns, rows)
+	postgresInstance.afterQuery(ctx, "COPY "+table+" FROM STDIN", nil, started, count, err)
+
+	return count, postgresInstance.wrapError(err)
+}
+
+// parseQualifiedName splits name into its schema and table, or returns its table alone if it
+// is not qualified, following the quoting rules of SQL identifiers.
+func parseQualifiedName(name string) ([]string, error) {
+	var names []string
+	position := 0
+	for {
+		var part string
+		if position < len(name) && name[position] == '"' {
+			var quoted strings.Builder
+			closed := false
+			for position++; position < len(name) && !closed; position++ {
+				switch {
+				case name[position] != '"':
+					quoted.WriteByte(name[position])
+				case position+1 < len(name) && name[position+1] == '"':
+					quoted.WriteByte('"')
+					position++
+				default:
+					closed = true
+				}
+			}
+			if !closed || quoted.Len() == 0 {
+				return nil, errors.Errorf("invalid table name %q", name)
+			}
+			part = quoted.String()
+		} else {
+			end := strings.IndexAny(name[position:], `."`)
+			if end < 0 {
+				end = len(name) - position
+			}
+			part = strings.ToLower(strings.TrimSpace(name[position : position+end]))
+			if part == "" {
+				return nil, errors.Errorf("invalid table name %q", name)
+			}
+			position += end
+		}
+		names = append(names, part)
+
+		if position == len(name) {
+			break
+		}
+		if name[position] != '.' || len(names) == 2 {
+			return nil, errors.Errorf("invalid table name %q, expected table or schema.table", name)
+		}
+		position++
+	}
+	return names, nil
+}
+
+// copyInStatement returns the COPY FROM STDIN statement lib/pq runs as a copy for names,
+// the table optionally preceded by its schema, and columns, all quoted as identifiers.
+func copyInStatement(names, columns []string) string {
+	quotedNames := make([]string, len(names))
+	for i, name := range names {
+		quotedNames[i] = quoteName(name)
+	}
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = quoteName(column)
+	}
+	return "COPY " + strings.Join(quotedNames, ".") + " (" + strings.Join(quotedColumns, ", ") + ") FROM STDIN"
+}
+
+// copyFrom streams every row into a COPY FROM STDIN statement inside a transaction.
+func copyFrom(ctx context.Context, postgresInstance *postgres, table string, columns []string, rows [][]any) (count int64, err error) {
+	if table == "" {
+		return 0, errors.New("copy from requires a table name")
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("copy from requires at least one column")
+	}
+
+	names, err := parseQualifiedName(table)
+	if err != nil {
+		return 0, err
+	}
+	statement := copyInStatement(names, columns)
+
+	transaction, err := postgresInstance.database.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer func() {
+		if panicValue := recover(); panicValue != nil {
+			_ = transaction.Rollback()
+			panic(panicValue)
+		} else if err != nil {
+			err = joinRollbackError(err, transaction.Rollback())
+		} else {
+			err = errors.WithStack(transaction.Commit())
+		}
+	}()
+
+	preparedStatement, err := transaction.PrepareContext(ctx, statement)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer preparedStatement.Close()
+
+	for index, row := range rows {
+		if len(row) != len(columns) {
+			return 0, errors.Errorf("copy from row %d has %d values, expected %d", index, len(row), len(columns))
+		}
+		if _, err = preparedStatement.ExecContext(ctx, row...); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+
+	// Flush the buffered rows and read the number of rows copied
+	result, err := preparedStatement.ExecContext(ctx)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if count, err = result.RowsAffected(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return count, nil
+}
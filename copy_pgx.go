@@ -0,0 +1,50 @@
+//go:build pgx
+
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// copyToSupported reports whether this build can run COPY TO STDOUT on the server.
+const copyToSupported = true
+
+// copyToServer runs statement, a COPY ... TO STDOUT, on a connection of database and streams
+// the output of the server to w. It returns the number of rows copied.
+func copyToServer(ctx context.Context, database *sqlx.DB, w io.Writer, statement string) (int64, error) {
+	conn, err := database.Connx(ctx)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	var count int64
+	err = conn.Raw(func(driverConn any) error {
+		for {
+			wrapped, ok := driverConn.(interface{ Unwrap() driver.Conn })
+			if !ok {
+				break
+			}
+			driverConn = wrapped.Unwrap()
+		}
+
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.Errorf("copy to requires the pgx driver, got %T", driverConn)
+		}
+
+		commandTag, err := stdlibConn.Conn().PgConn().CopyTo(ctx, w, statement)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		count = commandTag.RowsAffected()
+		return nil
+	})
+	return count, err
+}
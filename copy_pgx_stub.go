@@ -0,0 +1,20 @@
+//go:build !pgx
+
+package postgres
+
+import (
+	"context"
+	"io"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// copyToSupported reports whether this build can run COPY TO STDOUT on the server.
+// It requires the pgx driver, which is only compiled in with the "pgx" build tag.
+const copyToSupported = false
+
+// copyToServer is not available without the "pgx" build tag.
+func copyToServer(ctx context.Context, database *sqlx.DB, w io.Writer, statement string) (int64, error) {
+	return 0, errors.New("copy to on the server requires building with the pgx build tag")
+}
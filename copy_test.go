@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestFormatCopyValue(t *testing.T) {
+	india := time.FixedZone("IST", 5*3600+30*60)
+	pacific := time.FixedZone("PST", -8*3600)
+	moment := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    any
+		typeName string
+		want     string
+	}{
+		{"null", nil, "TEXT", `\N`},
+		{"text", "hello", "TEXT", "hello"},
+		{"numeric bytes", []byte("12.50"), "NUMERIC", "12.50"},
+		{"bytea", []byte{0xde, 0xad, 0xbe, 0xef}, "BYTEA", `\xdeadbeef`},
+		{"empty bytea", []byte{}, "BYTEA", `\x`},
+		{"true", true, "BOOL", "t"},
+		{"false", false, "BOOL", "f"},
+		{"int", int64(-42), "INT8", "-42"},
+		{"million", 1e6, "FLOAT8", "1000000"},
+		{"fraction", 0.1, "FLOAT8", "0.1"},
+		{"small", 0.0001, "FLOAT8", "0.0001"},
+		{"tiny", 0.00001, "FLOAT8", "1e-05"},
+		{"below float8 exponent limit", 1e14, "FLOAT8", "100000000000000"},
+		{"float8 exponent limit", 1e15, "FLOAT8", "1e+15"},
+		{"float4 scanned as float64", float64(float32(1.1)), "FLOAT4", "1.1"},
+		{"float4 exponent limit", float32(1e6), "FLOAT4", "1e+06"},
+		{"nan", math.NaN(), "FLOAT8", "NaN"},
+		{"infinity", math.Inf(1), "FLOAT8", "Infinity"},
+		{"negative infinity", math.Inf(-1), "FLOAT8", "-Infinity"},
+		{"date", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "DATE", "2024-01-01"},
+		{"timestamp", moment, "TIMESTAMP", "2024-01-02 03:04:05.123456"},
+		{"timestamptz utc", moment, "TIMESTAMPTZ", "2024-01-02 03:04:05.123456+00"},
+		{"timestamptz whole seconds", moment.Truncate(time.Second).In(pacific), "TIMESTAMPTZ", "2024-01-01 19:04:05-08"},
+		{"timestamptz half hour offset", moment.In(india), "TIMESTAMPTZ", "2024-01-02 08:34:05.123456+05:30"},
+		{"time", time.Date(0, 1, 1, 13, 14, 15, 0, time.UTC), "TIME", "13:14:15"},
+		{"timetz", time.Date(0, 1, 1, 13, 14, 15, 0, pacific), "TIMETZ", "13:14:15-08"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatCopyValue(test.value, test.typeName, `\N`); got != test.want {
+				t.Errorf("formatCopyValue(%#v, %q) = %q, want %q", test.value, test.typeName, got, test.want)
+			}
+		})
+	}
+}
+
+func TestEscapeCopyText(t *testing.T) {
+	if got, want := escapeCopyText(formatCopyValue([]byte{0x01}, "BYTEA", `\N`), false), `\\x01`; got != want {
+		t.Errorf("bytea in text format = %q, want %q", got, want)
+	}
+	if got, want := escapeCopyText("a\tb\nc\\d", false), `a\tb\nc\\d`; got != want {
+		t.Errorf("escapeCopyText = %q, want %q", got, want)
+	}
+	if got := escapeCopyText(`\N`, true); got != `\N` {
+		t.Errorf("NULL marker was escaped to %q", got)
+	}
+}
+
+func TestCopyToCSVTellsEmptyFromNull(t *testing.T) {
+	db := newFakeDriver(func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+		return fakeResult{
+			columns: []string{"id", "name", "note"},
+			rows: [][]driver.Value{
+				{int64(1), "", nil},
+				{int64(2), nil, `say "hi", then leave`},
+				{int64(3), "two\nlines", "plain"},
+			},
+		}, nil
+	}).client(t)
+
+	var output strings.Builder
+	count, err := db.CopyTo(context.Background(), &output, "SELECT id, name, note FROM users", WithCopyFormat(CopyFormatCSV), WithCopyHeader())
+	if err != nil || count != 3 {
+		t.Fatalf("CopyTo = %d, %v, want 3 rows", count, err)
+	}
+	want := "id,name,note\n" +
+		`1,"",` + "\n" +
+		`2,,"say ""hi"", then leave"` + "\n" +
+		"3,\"two\nlines\",plain\n"
+	if output.String() != want {
+		t.Errorf("CopyTo wrote %q, want %q", output.String(), want)
+	}
+}
+
+func TestQuoteCopyCSV(t *testing.T) {
+	tests := []struct {
+		value        string
+		isNull       bool
+		singleColumn bool
+		want         string
+	}{
+		{"", true, false, ""},
+		{"", false, false, `""`},
+		{"plain", false, false, "plain"},
+		{" spaced ", false, false, " spaced "},
+		{"a,b", false, false, `"a,b"`},
+		{`a"b`, false, false, `"a""b"`},
+		{"a\rb", false, false, "\"a\rb\""},
+		{`\.`, false, false, `\.`},
+		{`\.`, false, true, `"\."`},
+	}
+	for _, test := range tests {
+		if got := quoteCopyCSV(test.value, test.isNull, test.singleColumn); got != test.want {
+			t.Errorf("quoteCopyCSV(%q, %v, %v) = %s, want %s", test.value, test.isNull, test.singleColumn, got, test.want)
+		}
+	}
+}
+
+func TestCopyToStatement(t *testing.T) {
+	tests := []struct {
+		cfg  copyConfig
+		want string
+	}{
+		{copyConfig{format: CopyFormatText}, "COPY (SELECT * FROM users) TO STDOUT"},
+		{copyConfig{format: CopyFormatCSV}, "COPY (SELECT * FROM users) TO STDOUT WITH (FORMAT csv)"},
+		{copyConfig{format: CopyFormatCSV, header: true}, "COPY (SELECT * FROM users) TO STDOUT WITH (FORMAT csv, HEADER)"},
+	}
+	for _, test := range tests {
+		if got := copyToStatement(" SELECT * FROM users; ", &test.cfg); got != test.want {
+			t.Errorf("copyToStatement(%+v) = %s, want %s", test.cfg, got, test.want)
+		}
+	}
+}
+
+func TestCopyInStatementMatchesLibPQ(t *testing.T) {
+	columns := []string{"id", `say "hi"`, "Name"}
+	if got, want := copyInStatement([]string{"users"}, columns), pq.CopyIn("users", columns...); got != want {
+		t.Errorf("copyInStatement = %s, want %s", got, want)
+	}
+	if got, want := copyInStatement([]string{"Sales", "Q1.2024"}, columns), pq.CopyInSchema("Sales", "Q1.2024", columns...); got != want {
+		t.Errorf("copyInStatement with schema = %s, want %s", got, want)
+	}
+}
+
+func TestParseQualifiedName(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"users", []string{"users"}},
+		{"Public.Users", []string{"public", "users"}},
+		{`"Sales"."Q1.2024"`, []string{"Sales", "Q1.2024"}},
+		{`app."say ""hi"""`, []string{"app", `say "hi"`}},
+		{`"my.schema".events`, []string{"my.schema", "events"}},
+	}
+	for _, test := range tests {
+		got, err := parseQualifiedName(test.name)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseQualifiedName(%q) = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+
+	for _, name := range []string{"", ".users", "public.", "a.b.c", `"open`, `"abc""`, `""`, `"a"b`, `a"b"`} {
+		if got, err := parseQualifiedName(name); err == nil {
+			t.Errorf("parseQualifiedName(%q) = %q, want an error", name, got)
+		}
+	}
+}
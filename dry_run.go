@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"fmt"
+	"maps"
+)
+
+// DryRunQuery is a query as it would be sent to the database, with its resolved arguments.
+type DryRunQuery struct {
+	Query     string
+	Arguments map[string]any
+}
+
+// PendingResult stands in for the result of an earlier pipeline query in dry run arguments,
+// since that result is only known once the pipeline executes.
+type PendingResult struct {
+	Index int    // Position of the referenced query in the pipeline
+	Query string // Query text the result is stored under
+}
+
+// DryRun returns the query and arguments One, Many and Rows would execute, without
+// connecting to the database.
+func (query *selectQuery) DryRun() (DryRunQuery, error) {
+	arguments := query.arguments
+	if arguments == nil {
+		var err error
+		if arguments, err = pairsWithMapper(query.keyValuePairs, query.postgres.fieldMapper); err != nil {
+			return DryRunQuery{}, err
+		}
+	}
+
+	statement, arguments := expandInClauses(query.query, arguments)
+	return DryRunQuery{Query: statement, Arguments: arguments}, nil
+}
+
+// DryRun returns the query and arguments One, Many and Rows would execute, without
+// connecting to the database. Arguments are keyed by their placeholder, e.g. $1.
+func (query *positionalSelectQuery) DryRun() (DryRunQuery, error) {
+	return DryRunQuery{Query: query.query, Arguments: positionalArguments(query.arguments)}, nil
+}
+
+// DryRun returns the queries and arguments Exec or ExecInTx would execute, in order,
+// without connecting to the database. A query is returned on its own, while a pipeline
+// returns its SetLocal statements followed by every step; either is preceded by the
+// statement_timeout setting of WithStatementTimeout. Arguments referencing the
+// result of an earlier step through FromResult resolve to a PendingResult.
+func (e *execQuery) DryRun() ([]DryRunQuery, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	shared, err := pairsWithMapper(e.sharedArgs, e.postgres.fieldMapper)
+	if err != nil {
+		return nil, err
+	}
+
+	var queries []DryRunQuery
+	if e.statementTimeout > 0 {
+		query, arguments := setLocalQuery(statementTimeoutSetting, statementTimeoutValue(e.statementTimeout))
+		queries = append(queries, DryRunQuery{Query: query, Arguments: arguments})
+	}
+
+	if !e.pipeline.isTrans() && len(e.localSettings) == 0 {
+		arguments, err := pairsWithMapper(e.keyValuePairs, e.postgres.fieldMapper)
+		if err != nil {
+			return nil, err
+		}
+		statement, arguments := expandInClauses(e.query, mergeArguments(shared, arguments))
+		return append(queries, DryRunQuery{Query: statement, Arguments: arguments}), nil
+	}
+
+	for _, setting := range e.localSettings {
+		query, arguments := setLocalQuery(setting.name, setting.value)
+		queries = append(queries, DryRunQuery{Query: query, Arguments: arguments})
+	}
+
+	steps, err := e.pipeline.dryRun(e.query, e.keyValuePairs, e.label, e.postgres.resultHook, e.postgres.fieldMapper, shared)
+	if err != nil {
+		return nil, err
+	}
+	return append(queries, steps...), nil
+}
+
+// dryRun resolves the arguments of every query like runPipeline, with first prepended
+// like ExecInTx does, without executing anything or changing the pipeline.
+func (p *pipeline) dryRun(first string, firstKeyValuePairs []any, firstLabel string, hook string, fieldMapper func(string) string, shared map[string]any) ([]DryRunQuery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := p.queryKeys
+	parameters := p.queryParameters
+	labels := p.queryLabels
+	if first != "" {
+		firstKey := p.uniqueQueryLocked(first)
+		keys = append([]string{firstKey}, keys...)
+		parameters = maps.Clone(parameters)
+		parameters[firstKey] = firstKeyValuePairs
+		if firstLabel != "" {
+			labels = maps.Clone(labels)
+			labels[firstKey] = firstLabel
+		}
+	}
+
+	pending := make(map[string]any, len(keys))
+	queries := make([]DryRunQuery, 0, len(keys))
+	for index, query := range keys {
+		arguments, err := pairsHookWithMapper(parameters[query], pending, hook, fieldMapper)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve parameters for %s: %w", describeStep(index, query, labels[query]), err)
+		}
+		statement, arguments := expandInClauses(query, mergeArguments(shared, arguments))
+		queries = append(queries, DryRunQuery{Query: statement, Arguments: arguments})
+
+		result := PendingResult{Index: index, Query: query}
+		pending[query] = result
+		if label := labels[query]; label != "" {
+			pending[label] = result
+		}
+	}
+	return queries, nil
+}
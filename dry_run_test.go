@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExecDryRunResolvesPipeline(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t)
+
+	const insertUser = "INSERT INTO users (name) VALUES (:name) RETURNING id"
+	const insertOrder = "INSERT INTO orders (user_id, item, tenant_id) VALUES (:user_id, :item, :tenant_id) RETURNING id"
+	exec := db.Insert(insertUser, "name", "Alice").
+		WithStatementTimeout(2*time.Second).
+		SetLocal("app.user", "admin").
+		WithSharedArgs("tenant_id", 7)
+	exec.Insert(insertOrder, "user_id", exec.FromResult(insertUser), "item", "book")
+
+	queries, err := exec.DryRun()
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	want := []DryRunQuery{
+		{Query: "SELECT set_config(:name, :value, true)", Arguments: map[string]any{"name": "statement_timeout", "value": "2000"}},
+		{Query: "SELECT set_config(:name, :value, true)", Arguments: map[string]any{"name": "app.user", "value": "admin"}},
+		{Query: insertUser, Arguments: map[string]any{"name": "Alice", "tenant_id": 7}},
+		{Query: insertOrder, Arguments: map[string]any{
+			"user_id":   PendingResult{Index: 0, Query: insertUser},
+			"item":      "book",
+			"tenant_id": 7,
+		}},
+	}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("DryRun() = %#v, want %#v", queries, want)
+	}
+	if opens := fake.opens.Load(); opens != 0 {
+		t.Errorf("DryRun opened %d connections, want none", opens)
+	}
+}
+
+func TestSelectDryRunExpandsInClauses(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t)
+
+	var ids []int64
+	query, err := db.Select("SELECT id FROM users WHERE id IN (:ids) AND active = :active", &ids, "ids", []int{1, 2}, "active", true).DryRun()
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	want := DryRunQuery{
+		Query:     "SELECT id FROM users WHERE id IN (:__in_ids_0, :__in_ids_1) AND active = :active",
+		Arguments: map[string]any{"ids": []int{1, 2}, "__in_ids_0": 1, "__in_ids_1": 2, "active": true},
+	}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("DryRun() = %#v, want %#v", query, want)
+	}
+
+	positional, err := db.SelectPositional("SELECT id FROM users WHERE id = $1", &ids, 5).DryRun()
+	if err != nil || !reflect.DeepEqual(positional.Arguments, map[string]any{"$1": 5}) {
+		t.Errorf("positional DryRun() = %#v, %v, want its argument keyed $1", positional, err)
+	}
+	if opens := fake.opens.Load(); opens != 0 {
+		t.Errorf("DryRun opened %d connections, want none", opens)
+	}
+}
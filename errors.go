@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when a query that must return a row returns none.
+	// Errors matching ErrNotFound also match sql.ErrNoRows.
+	ErrNotFound = stderrors.New("postgres: no rows found")
+
+	// ErrMissingWhere is returned when WithRequireWhere is set and an UPDATE or DELETE
+	// has no WHERE clause.
+	ErrMissingWhere = stderrors.New("postgres: update or delete without a WHERE clause")
+
+	// ErrMissingArguments is returned before executing a query that references a :name
+	// parameter with no matching key in its key-value pairs.
+	ErrMissingArguments = stderrors.New("postgres: missing arguments for named parameters")
+
+	// ErrStatementTimeout is returned when the server cancels a statement of a query set up with
+	// WithStatementTimeout for running longer than the timeout. It also unwraps to the driver error.
+	ErrStatementTimeout = stderrors.New("postgres: statement timeout")
+
+	// ErrNoReturningID is returned when an insert run for its ID returns no row or a NULL ID,
+	// e.g. because the query has no RETURNING clause. Use NoReturn for inserts without one.
+	ErrNoReturningID = stderrors.New("postgres: insert returned no ID")
+
+	errNoRows = fmt.Errorf("%w: %w", ErrNotFound, sql.ErrNoRows)
+)
+
+// stackTracer is implemented by errors created or wrapped by github.com/pkg/errors.
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+const (
+	sqlStateNotNullViolation     = "23502"
+	sqlStateForeignKeyViolation  = "23503"
+	sqlStateUniqueViolation      = "23505"
+	sqlStateQueryCanceled        = "57014"
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// sqlStater is implemented by driver errors that carry a SQLSTATE code,
+// such as *pq.Error and pgx's *pgconn.PgError.
+type sqlStater interface {
+	SQLState() string
+}
+
+// sqlState returns the SQLSTATE code of the first driver error in the chain, if any.
+func sqlState(err error) string {
+	var stater sqlStater
+	if stderrors.As(err, &stater) {
+		return stater.SQLState()
+	}
+	return ""
+}
+
+// IsUniqueViolation returns true if err was caused by a unique constraint violation (SQLSTATE 23505).
+func IsUniqueViolation(err error) bool {
+	return sqlState(err) == sqlStateUniqueViolation
+}
+
+// IsForeignKeyViolation returns true if err was caused by a foreign key violation (SQLSTATE 23503).
+func IsForeignKeyViolation(err error) bool {
+	return sqlState(err) == sqlStateForeignKeyViolation
+}
+
+// IsNotNullViolation returns true if err was caused by a not-null constraint violation (SQLSTATE 23502).
+func IsNotNullViolation(err error) bool {
+	return sqlState(err) == sqlStateNotNullViolation
+}
+
+// isRetryable returns true if the transaction failed in a way that is safe to retry.
+func isRetryable(err error) bool {
+	switch sqlState(err) {
+	case sqlStateSerializationFailure, sqlStateDeadlockDetected:
+		return true
+	default:
+		return false
+	}
+}
+
+// markStatementTimeout makes err match ErrStatementTimeout if the server cancelled the statement
+// while ctx was still live, so the cancellation came from statement_timeout rather than ctx.
+func markStatementTimeout(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil || sqlState(err) != sqlStateQueryCanceled || stderrors.Is(err, ErrStatementTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrStatementTimeout, err)
+}
+
+// joinRollbackError joins the error of rolling back a failed transaction to the error that
+// caused the rollback, keeping the cause first. A transaction already closed by the server
+// is not an extra failure, so sql.ErrTxDone is dropped.
+func joinRollbackError(err, rollbackErr error) error {
+	if rollbackErr == nil || stderrors.Is(rollbackErr, sql.ErrTxDone) {
+		return err
+	}
+	return stderrors.Join(err, errors.WithStack(fmt.Errorf("rollback failed: %w", rollbackErr)))
+}
+
+// plainWrapError is a wrapping error without a stack trace.
+// It keeps the message of the error it replaces and unwraps to the stripped cause.
+type plainWrapError struct {
+	msg string
+	err error
+}
+
+func (e *plainWrapError) Error() string {
+	return e.msg
+}
+
+func (e *plainWrapError) Unwrap() error {
+	return e.err
+}
+
+// plainJoinError is plainWrapError for errors wrapping several errors, such as errors.Join.
+type plainJoinError struct {
+	msg  string
+	errs []error
+}
+
+func (e *plainJoinError) Error() string {
+	return e.msg
+}
+
+func (e *plainJoinError) Unwrap() []error {
+	return e.errs
+}
+
+// wrapError applies the client's error policy to an error returned from a public method.
+func (postgresInstance *postgres) wrapError(err error) error {
+	if err == nil || !postgresInstance.withoutStackTraces {
+		return err
+	}
+	return stripStackTraces(err)
+}
+
+// stripStackTraces removes every github.com/pkg/errors stack trace from the error chain.
+// The error message is unchanged and the chain still unwraps to the original cause,
+// so errors.Is and errors.As keep working on the result.
+func stripStackTraces(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := joined.Unwrap()
+		stripped := make([]error, len(errs))
+		changed := false
+		for i, inner := range errs {
+			stripped[i] = stripStackTraces(inner)
+			changed = changed || stripped[i] != inner
+		}
+		if !changed {
+			return err
+		}
+		return &plainJoinError{msg: err.Error(), errs: stripped}
+	}
+
+	inner := stderrors.Unwrap(err)
+	if inner == nil {
+		if _, ok := err.(stackTracer); ok {
+			return stderrors.New(err.Error())
+		}
+		return err
+	}
+
+	if _, ok := err.(stackTracer); ok {
+		return stripStackTraces(inner)
+	}
+
+	stripped := stripStackTraces(inner)
+	if stripped == inner {
+		return err
+	}
+	return &plainWrapError{msg: err.Error(), err: stripped}
+}
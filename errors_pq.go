@@ -0,0 +1,23 @@
+//go:build !nopq
+
+package postgres
+
+import (
+	stderrors "errors"
+
+	"github.com/lib/pq"
+)
+
+// AsPQError returns the *pq.Error in the chain of err, if any.
+// Errors returned by this package are wrapped with stack traces, so a direct
+// type assertion like err.(*pq.Error) fails; AsPQError unwraps the chain instead.
+//
+// It is the only code linking lib/pq, and with it its "postgres" driver, into the package.
+// Build with the "nopq" build tag to leave both out, e.g. when only using pgx.
+func AsPQError(err error) (*pq.Error, bool) {
+	var pqErr *pq.Error
+	if stderrors.As(err, &pqErr) {
+		return pqErr, true
+	}
+	return nil, false
+}
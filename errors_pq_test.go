@@ -0,0 +1,23 @@
+//go:build !nopq
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestAsPQError(t *testing.T) {
+	driverErr := &pq.Error{Code: sqlStateUniqueViolation, Message: "duplicate key value"}
+	db := newFakeDriver(failingWith(driverErr)).client(t)
+
+	_, err := db.Update("UPDATE users SET email = :email WHERE id = :id", "email", "a@example.com", "id", 1).ExecUpdate(context.Background())
+	if pqErr, ok := AsPQError(err); !ok || pqErr != driverErr {
+		t.Errorf("AsPQError(%v) = %v, %v, want the driver error", err, pqErr, ok)
+	}
+	if pqErr, ok := AsPQError(context.Canceled); ok || pqErr != nil {
+		t.Errorf("AsPQError(context.Canceled) = %v, %v, want none", pqErr, ok)
+	}
+}
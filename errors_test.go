@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// failingWith answers every query with err.
+func failingWith(err error) fakeHandler {
+	return func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+		return fakeResult{}, err
+	}
+}
+
+// hasStackTrace reports whether any error in the chain of err carries a stack trace.
+func hasStackTrace(err error) bool {
+	var tracer stackTracer
+	return errors.As(err, &tracer)
+}
+
+func TestErrorsUnwrapToDriverError(t *testing.T) {
+	entryPoints := map[string]func(ctx context.Context, db *postgres) error{
+		"Select.One": func(ctx context.Context, db *postgres) error {
+			var id int64
+			_, err := db.Select("SELECT id FROM users WHERE id = :id", &id, "id", 1).One(ctx)
+			return err
+		},
+		"Select.Many": func(ctx context.Context, db *postgres) error {
+			var ids []int64
+			_, err := db.Select("SELECT id FROM users", &ids).Many(ctx)
+			return err
+		},
+		"SelectPositional.One": func(ctx context.Context, db *postgres) error {
+			var id int64
+			_, err := db.SelectPositional("SELECT id FROM users WHERE id = $1", &id, 1).One(ctx)
+			return err
+		},
+		"Scalar": func(ctx context.Context, db *postgres) error {
+			var id int64
+			return db.Scalar(ctx, "SELECT id FROM users WHERE id = :id", &id, "id", 1)
+		},
+		"Exec.ExecInsert": func(ctx context.Context, db *postgres) error {
+			_, err := db.Insert("INSERT INTO users (name) VALUES (:name) RETURNING id", "name", "Alice").ExecInsert(ctx)
+			return err
+		},
+		"Exec.ExecUpdate": func(ctx context.Context, db *postgres) error {
+			_, err := db.Update("UPDATE users SET name = :name WHERE id = :id", "name", "Alice", "id", 1).ExecUpdate(ctx)
+			return err
+		},
+		"Exec.ExecInTx": func(ctx context.Context, db *postgres) error {
+			_, err := db.Insert("INSERT INTO users (name) VALUES (:name) RETURNING id", "name", "Alice").
+				Update("UPDATE users SET active = :active", "active", true).
+				ExecInTx(ctx)
+			return err
+		},
+		"Transact": func(ctx context.Context, db *postgres) error {
+			return db.Transact(ctx, func(tx Tx) error {
+				_, err := tx.Update(ctx, "UPDATE users SET name = :name WHERE id = :id", "name", "Alice", "id", 1)
+				return err
+			})
+		},
+		"ReadTx": func(ctx context.Context, db *postgres) error {
+			return db.ReadTx(ctx, func(tx ReadTx) error {
+				var id int64
+				return tx.Scalar(ctx, "SELECT id FROM users WHERE id = :id", &id, "id", 1)
+			})
+		},
+	}
+
+	driverErr := &pq.Error{Code: sqlStateUniqueViolation, Message: "duplicate key value"}
+	for _, withoutStackTraces := range []bool{false, true} {
+		var opts []Option
+		if withoutStackTraces {
+			opts = append(opts, WithoutStackTraces())
+		}
+		db := newFakeDriver(failingWith(driverErr)).client(t, opts...)
+		for name, run := range entryPoints {
+			err := run(context.Background(), db)
+
+			var pqErr *pq.Error
+			if !errors.Is(err, driverErr) || !errors.As(err, &pqErr) || !IsUniqueViolation(err) {
+				t.Errorf("%s (withoutStackTraces %v) = %v, want it to unwrap to the driver error", name, withoutStackTraces, err)
+			}
+			if hasStackTrace(err) == withoutStackTraces {
+				t.Errorf("%s (withoutStackTraces %v): stack trace in chain = %v", name, withoutStackTraces, !withoutStackTraces)
+			}
+		}
+	}
+}
+
+func TestNotFoundMatchesErrNoRows(t *testing.T) {
+	db := newFakeDriver(func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+		return fakeResult{columns: []string{"id"}}, nil
+	}).client(t)
+
+	var id int64
+	err := db.Scalar(context.Background(), "SELECT id FROM users WHERE id = :id", &id, "id", 1)
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Scalar = %v, want it to match ErrNotFound and sql.ErrNoRows", err)
+	}
+}
+
+func TestInsertWithoutReturningID(t *testing.T) {
+	db := newFakeDriver(nil).client(t)
+	ctx := context.Background()
+	const insert = "INSERT INTO user_tags (user_id, tag) VALUES (:user_id, :tag)"
+
+	if _, err := db.Insert(insert, "user_id", 1, "tag", "admin").ExecInsert(ctx); !errors.Is(err, ErrNoReturningID) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ExecInsert = %v, want ErrNoReturningID", err)
+	}
+	_, err := db.Insert(insert, "user_id", 1, "tag", "admin").
+		Insert(insert, "user_id", 2, "tag", "admin").
+		ExecInTx(ctx)
+	if !errors.Is(err, ErrNoReturningID) {
+		t.Errorf("ExecInTx = %v, want ErrNoReturningID", err)
+	}
+
+	nullID := newFakeDriver(func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+		return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{nil}}, rowsAffected: 1}, nil
+	}).client(t)
+	if _, err := nullID.Insert(insert+" RETURNING id", "user_id", 1, "tag", "admin").ExecInsert(ctx); !errors.Is(err, ErrNoReturningID) {
+		t.Errorf("ExecInsert returning a NULL id = %v, want ErrNoReturningID", err)
+	}
+}
+
+func TestPipelineGuardErrors(t *testing.T) {
+	guards := map[string]func(ctx context.Context, db *postgres) error{
+		"Exec.ExecUpdate on a pipeline": func(ctx context.Context, db *postgres) error {
+			_, err := db.Update("UPDATE users SET name = :name WHERE id = :id", "name", "Alice", "id", 1).
+				Update("UPDATE users SET active = :active WHERE id = :id", "active", true, "id", 1).
+				ExecUpdate(ctx)
+			return err
+		},
+		"Exec.ExecInsert with SetLocal": func(ctx context.Context, db *postgres) error {
+			_, err := db.Insert("INSERT INTO users (name) VALUES (:name) RETURNING id", "name", "Alice").
+				SetLocal("app.user", "admin").
+				ExecInsert(ctx)
+			return err
+		},
+		"Exec.ExecInTx without a pipeline": func(ctx context.Context, db *postgres) error {
+			_, err := db.Insert("INSERT INTO users (name) VALUES (:name) RETURNING id", "name", "Alice").ExecInTx(ctx)
+			return err
+		},
+	}
+
+	for _, withoutStackTraces := range []bool{false, true} {
+		var opts []Option
+		if withoutStackTraces {
+			opts = append(opts, WithoutStackTraces())
+		}
+		fake := newFakeDriver(nil)
+		db := fake.client(t, opts...)
+		for name, run := range guards {
+			err := run(context.Background(), db)
+			if err == nil || !strings.Contains(err.Error(), "invalid operation") {
+				t.Errorf("%s = %v, want an invalid operation error", name, err)
+			}
+			if hasStackTrace(err) == withoutStackTraces {
+				t.Errorf("%s (withoutStackTraces %v): stack trace in chain = %v", name, withoutStackTraces, !withoutStackTraces)
+			}
+		}
+		if queries := fake.queries.Load(); queries != 0 {
+			t.Errorf("guards ran %d queries, want none", queries)
+		}
+	}
+}
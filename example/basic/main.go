@@ -105,7 +105,7 @@ func setupDatabase(ctx context.Context, db postgres.Postgres) {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
 
-	_, err := db.Update(usersTable).Exec(ctx)
+	_, err := db.Update(usersTable).ExecUpdate(ctx)
 	if err != nil {
 		log.Fatalf("Failed to create users table: %v", err)
 	}
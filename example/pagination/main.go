@@ -101,7 +101,7 @@ func setupDatabase(ctx context.Context, db postgres.Postgres) {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
 
-	_, err := db.Update(usersTable).Exec(ctx)
+	_, err := db.Update(usersTable).ExecUpdate(ctx)
 	if err != nil {
 		log.Fatalf("Failed to create users table: %v", err)
 	}
@@ -119,6 +119,6 @@ func seedDatabase(ctx context.Context, db postgres.Postgres) {
 		_, _ = db.Insert("INSERT INTO users (name, email, status) VALUES (:name, :email, :status)",
 			"name", fmt.Sprintf("User %d", i),
 			"email", fmt.Sprintf("user%d@example.com", i),
-			"status", "active").Exec(ctx)
+			"status", "active").ExecUpdate(ctx)
 	}
 }
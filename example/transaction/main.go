@@ -70,12 +70,12 @@ func setupDatabase(ctx context.Context, db postgres.Postgres) {
 		bio TEXT
 	);`
 
-	_, err := db.Update(usersTable).Exec(ctx)
+	_, err := db.Update(usersTable).ExecUpdate(ctx)
 	if err != nil {
 		log.Fatalf("Failed to create users table: %v", err)
 	}
 
-	_, err = db.Update(profilesTable).Exec(ctx)
+	_, err = db.Update(profilesTable).ExecUpdate(ctx)
 	if err != nil {
 		log.Fatalf("Failed to create profiles table: %v", err)
 	}
@@ -0,0 +1,83 @@
+package postgres_test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	postgres "github.com/andryhardiyanto/go-postgres"
+	"github.com/jmoiron/sqlx"
+)
+
+// Unit test code using the Postgres interface with go-sqlmock: every query is prepared with
+// its named parameters rewritten to $N, then queried or executed.
+func ExampleNewWithDB() {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		panic(err)
+	}
+	defer mockDB.Close()
+
+	db, err := postgres.NewWithDB(sqlx.NewDb(mockDB, "postgres"))
+	if err != nil {
+		panic(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO users (name) VALUES ($1) RETURNING id")).
+		ExpectQuery().WithArgs("Alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	id, err := db.Insert("INSERT INTO users (name) VALUES (:name) RETURNING id", "name", "Alice").ExecInsert(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	var names []string
+	mock.ExpectPrepare(regexp.QuoteMeta("SELECT name FROM users WHERE id = $1")).
+		ExpectQuery().WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Alice"))
+	found, err := db.Select("SELECT name FROM users WHERE id = :id", &names, "id", 1).Many(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(id, found, names, mock.ExpectationsWereMet())
+	// Output: 1 true [Alice] <nil>
+}
+
+// Mock a transaction pipeline: the steps run between a begin and a commit. Insert steps return
+// the id through a query, while update and delete steps run as an exec.
+func ExampleNewWithDB_transaction() {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		panic(err)
+	}
+	defer mockDB.Close()
+
+	db, err := postgres.NewWithDB(sqlx.NewDb(mockDB, "postgres"))
+	if err != nil {
+		panic(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO orders (item) VALUES ($1) RETURNING id")).
+		ExpectQuery().WithArgs("book").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE stock SET count = count - 1 WHERE item = $1")).
+		ExpectExec().WithArgs("book").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	insert := "INSERT INTO orders (item) VALUES (:item) RETURNING id"
+	update := "UPDATE stock SET count = count - 1 WHERE item = :item"
+	result, err := db.Insert(insert, "item", "book").
+		Update(update, "item", "book").
+		ExecInTx(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(result.TxResult(insert), result.RowsAffected(update), mock.ExpectationsWereMet())
+	// Output: 7 1 <nil>
+}
@@ -2,27 +2,74 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
 // execQuery is a query that executes a statement against the database.
 type execQuery struct {
-	postgres      *postgres
-	query         string
-	keyValuePairs []any
-	pipeline      *pipeline
-	debug         bool
+	postgres         *postgres
+	query            string
+	keyValuePairs    []any
+	sharedArgs       []any // Key-value pairs merged into the arguments of every step
+	pipeline         *pipeline
+	debug            bool
+	localSettings    []localSetting
+	returning        any
+	txOptions        *sql.TxOptions
+	retryAttempts    int
+	retryBackoff     time.Duration
+	label            string
+	optional         bool
+	onCommit         []func()
+	onRollback       []func(err error)
+	statementTimeout time.Duration // Server-side statement_timeout, see WithStatementTimeout
+	noReturn         bool          // Insert reports affected rows instead of a returned ID
+	allowFull        bool          // UPDATE and DELETE may run without WHERE despite WithRequireWhere
+	err              error         // Error from building the query, returned by Exec and ExecInTx
+}
+
+// localSetting is a transaction-scoped configuration parameter applied by ExecInTx.
+type localSetting struct {
+	name  string
+	value any // Text of the value, or nil to reset the parameter
 }
 
 // ExecResult is the result of an exec query.
 type ExecResult struct {
-	ids map[string]any
+	ids          map[string]any
+	rowsAffected map[string]int64
+	durations    map[string]time.Duration
+}
+
+// newExecResult creates an empty result with room for size queries.
+func newExecResult(size int) *ExecResult {
+	return &ExecResult{
+		ids:          make(map[string]any, size),
+		rowsAffected: make(map[string]int64, size),
+		durations:    make(map[string]time.Duration, size),
+	}
 }
 
+// Exec builds and runs a statement, or a transaction pipeline of statements run by ExecInTx.
+//
+// An Exec is a builder meant to be owned by one goroutine. Adding steps with Insert, Update,
+// Delete and Select and running them with ExecInTx is guarded, so an Exec shared by mistake
+// does not corrupt its pipeline, although the step order is then non-deterministic. ExecInTx
+// runs a snapshot of the steps built so far and leaves the Exec unchanged, so it can be run
+// again, also from several goroutines at once, without Reset. The
+// methods setting options, such as As, Optional, NoReturn, WithRetry and SetLocal, are not
+// synchronized and must not be called while the Exec is used by another goroutine.
 type Exec interface {
 	Debug() Exec
+	// Deprecated: the type of the result depends on the query; use ExecInsert or ExecUpdate instead.
 	Exec(ctx context.Context) (any, error)
+	ExecInsert(ctx context.Context) (any, error)
+	ExecUpdate(ctx context.Context) (int64, error)
 	ExecInTx(ctx context.Context) (result *ExecResult, err error)
 	Insert(query string, keyValuePairs ...any) Exec
 	Update(query string, keyValuePairs ...any) Exec
@@ -30,6 +77,22 @@ type Exec interface {
 	Select(query string, destination any, keyValuePairs ...any) Exec
 	Wrap(exec Exec) Exec
 	FromResult(from string) string
+	Reset() Exec
+	SetLocal(name string, value any) Exec
+	WithSharedArgs(keyValuePairs ...any) Exec
+	WithSchema(schema string) Exec
+	WithStatementTimeout(timeout time.Duration) Exec
+	Returning(destination any) Exec
+	WithIsolation(level sql.IsolationLevel) Exec
+	ReadOnly() Exec
+	WithRetry(maxAttempts int, backoff time.Duration) Exec
+	As(label string) Exec
+	Optional() Exec
+	NoReturn() Exec
+	OnCommit(hook func()) Exec
+	OnRollback(hook func(err error)) Exec
+	AllowFullTable() Exec
+	DryRun() ([]DryRunQuery, error)
 }
 
 func newExecQuery(postgresInstance *postgres, query string, keyValuePairs []any) Exec {
@@ -49,62 +112,286 @@ func (e *execQuery) Debug() Exec {
 func (e *execQuery) FromResult(from string) string {
 	return e.postgres.FromResult(e.pipeline.uniqueQuery(from))
 }
-func (e *execQuery) Exec(ctx context.Context) (any, error) {
-	if e.pipeline.isTrans() {
-		return 0, errors.New("invalid operation: this query is part of a transaction pipeline. Please use ExecInTx() method instead of Exec() to execute transaction-based queries")
+
+// Exec runs the query outside a transaction. It returns the ID returned by an INSERT or
+// MERGE ... RETURNING, the Returning destination if one is set, and the number of affected
+// rows otherwise.
+//
+// Deprecated: the type of the result depends on the query; use ExecInsert or ExecUpdate instead.
+func (e *execQuery) Exec(ctx context.Context) (result any, err error) {
+	result, _, err = e.exec(ctx, "postgres.Exec", false)
+	return result, err
+}
+
+// ExecInsert runs an INSERT ... RETURNING or MERGE ... RETURNING query outside a transaction
+// and returns the returned ID. Other queries return an error without being executed.
+func (e *execQuery) ExecInsert(ctx context.Context) (any, error) {
+	if !e.returnsID() {
+		return nil, e.postgres.wrapError(errors.Errorf("invalid operation: ExecInsert requires an INSERT or MERGE ... RETURNING query without Returning, got %q; use ExecUpdate instead", e.query))
 	}
-	arguments, err := Pairs(e.keyValuePairs)
+	result, _, err := e.exec(ctx, "postgres.ExecInsert", false)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return result, nil
+}
 
-	// Debug query if either global debug or instance debug is enabled
-	if e.debug {
-		debugQuery(e.query, arguments)
+// ExecUpdate runs the query outside a transaction and returns the number of affected rows,
+// for any statement including an INSERT with or without RETURNING. With Returning, the
+// RETURNING row is scanned into the destination and 1 is returned.
+func (e *execQuery) ExecUpdate(ctx context.Context) (int64, error) {
+	_, rowsAffected, err := e.exec(ctx, "postgres.ExecUpdate", true)
+	return rowsAffected, err
+}
+
+// returnsID returns true if Exec returns an ID rather than a row count or destination.
+func (e *execQuery) returnsID() bool {
+	if e.returning != nil {
+		return false
+	}
+	switch queryType(e.query) {
+	case qInsert:
+		return !e.noReturn
+	case qMerge:
+		return hasReturning(e.query)
+	}
+	return false
+}
+
+// exec runs the query outside a transaction and returns the result of Exec along with the
+// number of affected rows. If countRows is true, INSERT and MERGE queries are run for their
+// row count instead of a returned ID.
+func (e *execQuery) exec(ctx context.Context, name string, countRows bool) (result any, rowsAffected int64, err error) {
+	ctx, cancel := e.postgres.withDefaultTimeout(ctx)
+	defer cancel()
+
+	ctx, endSpan := e.postgres.startSpan(ctx, name, e.query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	if e.err != nil {
+		return 0, 0, e.postgres.wrapError(e.err)
+	}
+	if e.pipeline.isTrans() || len(e.localSettings) > 0 {
+		return 0, 0, e.postgres.wrapError(errors.New("invalid operation: this query is part of a transaction pipeline. Please use ExecInTx() method instead of Exec() to execute transaction-based queries"))
+	}
+	if e.postgres.requireWhere && !e.allowFull && missesWhere(e.query) {
+		return 0, 0, e.postgres.wrapError(errors.Wrapf(ErrMissingWhere, "query %q", e.query))
+	}
+	arguments, err := pairsWithMapper(e.keyValuePairs, e.postgres.fieldMapper)
+	if err != nil {
+		return 0, 0, e.postgres.wrapError(err)
+	}
+	shared, err := pairsWithMapper(e.sharedArgs, e.postgres.fieldMapper)
+	if err != nil {
+		return 0, 0, e.postgres.wrapError(err)
 	}
+	arguments = mergeArguments(shared, arguments)
+	if err = checkArguments(e.query, arguments); err != nil {
+		return 0, 0, e.postgres.wrapError(err)
+	}
+	statement, arguments := expandInClauses(e.query, arguments)
+
+	// Debug query if either global debug or instance debug is enabled
+	e.postgres.beforeQuery(ctx, e.debug, statement, arguments)
 
-	if queryType(e.query) == qInsert {
-		return insert(ctx, e.postgres.database, e.query, arguments)
+	started := time.Now()
+	rowsAffected = 1
+	if e.statementTimeout > 0 {
+		err = e.postgres.withStatementTimeout(ctx, e.postgres.database, e.statementTimeout, e.debug, func(transaction *sqlx.Tx) (err error) {
+			result, rowsAffected, err = e.execTx(ctx, transaction, statement, arguments, countRows)
+			return err
+		})
+	} else if e.returning != nil {
+		err = returning(ctx, e.postgres, statement, arguments, e.returning)
+		result = e.returning
+	} else if queryType(e.query) == qInsert && !e.noReturn && !countRows {
+		result, err = insert(ctx, e.postgres, statement, arguments)
+	} else if queryType(e.query) == qMerge && hasReturning(statement) && !countRows {
+		// MERGE ... RETURNING returns the first returned value like an insert
+		var returnedValue any
+		err = returning(ctx, e.postgres, statement, arguments, &returnedValue)
+		result = returnedValue
 	} else if queryType(e.query) == qDelete {
-		return delete(ctx, e.postgres.database, e.query, arguments)
+		rowsAffected, err = deleteRows(ctx, e.postgres, statement, arguments)
+		result = rowsAffected
+	} else {
+		rowsAffected, err = update(ctx, e.postgres, statement, arguments)
+		result = rowsAffected
+	}
+	if err != nil {
+		rowsAffected = 0
+	}
+	e.postgres.afterQuery(ctx, statement, arguments, started, rowsAffected, err)
+
+	return result, rowsAffected, e.postgres.wrapError(err)
+}
+
+// execTx runs statement in transaction like exec runs it on the pool.
+func (e *execQuery) execTx(ctx context.Context, transaction *sqlx.Tx, statement string, arguments map[string]any, countRows bool) (result any, rowsAffected int64, err error) {
+	statements := &txStatements{tx: transaction}
+	switch {
+	case e.returning != nil:
+		if result, err = selectTx(ctx, statements, statement, arguments, e.returning); err == nil && result == nil {
+			err = noReturnedRowError(sql.ErrNoRows)
+		}
+		return e.returning, 1, err
+	case queryType(e.query) == qInsert && !e.noReturn && !countRows:
+		result, err = insertTx(ctx, statements, statement, arguments)
+		return result, 1, err
+	case queryType(e.query) == qMerge && hasReturning(statement) && !countRows:
+		if result, err = returningTx(ctx, statements, statement, arguments); err == nil && result == nil {
+			err = noReturnedRowError(sql.ErrNoRows)
+		}
+		return result, 1, err
+	case queryType(e.query) == qDelete:
+		rowsAffected, err = deleteTx(ctx, statements, statement, arguments)
+	default:
+		rowsAffected, err = updateTx(ctx, statements, statement, arguments)
 	}
-	return update(ctx, e.postgres.database, e.query, arguments)
+	return rowsAffected, rowsAffected, err
 }
 
 func (e *execQuery) ExecInTx(ctx context.Context) (result *ExecResult, err error) {
-	if !e.pipeline.isTrans() {
-		return nil, errors.New("invalid operation: no transaction pipeline found. Please use Insert(), Update(), or Delete() methods to build a transaction pipeline before calling ExecInTx()")
+	ctx, cancel := e.postgres.withDefaultTimeout(ctx)
+	defer cancel()
+
+	ctx, endSpan := e.postgres.startSpan(ctx, "postgres.ExecInTx", e.query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	if e.err != nil {
+		return nil, e.postgres.wrapError(e.err)
+	}
+	if !e.pipeline.isTrans() && len(e.localSettings) == 0 {
+		return nil, e.postgres.wrapError(errors.New("invalid operation: no transaction pipeline found. Please use Insert(), Update(), or Delete() methods to build a transaction pipeline before calling ExecInTx()"))
+	}
+	plan := e.plan()
+	if e.postgres.requireWhere && !e.allowFull {
+		if query, missing := plan.missingWhereQuery(); missing {
+			return nil, e.postgres.wrapError(errors.Wrapf(ErrMissingWhere, "query %q", query))
+		}
+	}
+	shared, err := pairsWithMapper(e.sharedArgs, e.postgres.fieldMapper)
+	if err != nil {
+		return nil, e.postgres.wrapError(err)
+	}
+	if err = plan.checkArguments(shared, e.postgres.fieldMapper); err != nil {
+		return nil, e.postgres.wrapError(err)
+	}
+
+	attempts := max(e.retryAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		result, err = e.execInTx(ctx, plan, shared)
+		if err == nil || attempt >= attempts || !isRetryable(err) {
+			if e.statementTimeout > 0 {
+				err = markStatementTimeout(ctx, err)
+			}
+			return result, e.postgres.wrapError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, e.postgres.wrapError(errors.WithStack(ctx.Err()))
+		case <-time.After(e.retryBackoff):
+		}
+	}
+}
+
+// plan returns the steps ExecInTx runs: the exec's own query first, then a copy of its
+// pipeline. The pipeline itself is not modified, so the same Exec can run again unchanged.
+func (e *execQuery) plan() *pipeline {
+	plan := NewPipeline()
+	plan.appendPipeline(e.pipeline, e.postgres.resultHook)
+	key := plan.addFirstPipeline(e.query, e.keyValuePairs, e.label)
+	if e.optional {
+		plan.markOptional(key)
+	}
+	if e.noReturn {
+		plan.markNoReturn(key)
 	}
-	e.pipeline.addFirstPipeline(e.query, e.keyValuePairs)
+	return plan
+}
 
-	transaction, err := e.postgres.database.Beginx()
+// execInTx runs plan once in a new transaction, merging shared into the arguments of every step.
+func (e *execQuery) execInTx(ctx context.Context, plan *pipeline, shared map[string]any) (result *ExecResult, err error) {
+	// Independent pipelines on the pgx driver are sent as one batch on a dedicated connection
+	var conn *sqlx.Conn
+	if e.postgres.supportsBatch() && plan.batchable(e.postgres.resultHook) {
+		conn, err = e.postgres.database.Connx(ctx)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer conn.Close()
+	}
+
+	var transaction *sqlx.Tx
+	if conn != nil {
+		transaction, err = conn.BeginTxx(ctx, e.txOptions)
+	} else {
+		transaction, err = e.postgres.database.BeginTxx(ctx, e.txOptions)
+	}
 	if err != nil {
-		return nil, err
+		return nil, errors.WithStack(err)
 	}
 	defer func() {
 		if panicValue := recover(); panicValue != nil {
 			_ = transaction.Rollback()
+			e.runRollbackHooks(fmt.Errorf("transaction panicked: %v", panicValue))
 			panic(panicValue)
 		} else if err != nil {
-			_ = transaction.Rollback()
+			err = joinRollbackError(err, transaction.Rollback())
+			e.runRollbackHooks(err)
+		} else if err = errors.WithStack(transaction.Commit()); err != nil {
+			e.runRollbackHooks(err)
 		} else {
-			err = transaction.Commit()
+			e.runCommitHooks()
 		}
 	}()
 
-	result, err = e.pipeline.runPipeline(ctx, transaction, e.debug)
+	if e.statementTimeout > 0 {
+		if err = setLocalTx(ctx, e.postgres, transaction, statementTimeoutSetting, statementTimeoutValue(e.statementTimeout), e.debug); err != nil {
+			return nil, err
+		}
+	}
+	for _, setting := range e.localSettings {
+		if err = setLocalTx(ctx, e.postgres, transaction, setting.name, setting.value, e.debug); err != nil {
+			return nil, err
+		}
+	}
+
+	if conn != nil {
+		result, err = plan.runBatch(ctx, e.postgres, conn, e.debug, shared)
+	} else {
+		result, err = plan.runPipeline(ctx, e.postgres, transaction, e.debug, shared)
+	}
 
 	return
 }
 
+// Wrap appends the steps of exec to the pipeline: its own query first, then its queued steps
+// in the order they were added. Steps added to this exec after Wrap run after them, so the
+// pipeline runs in call order, e.g. a.Wrap(b).Insert(c) runs a, a's steps, b, b's steps, c.
+//
+// exec itself is not modified. A wrapped query whose text is already used in the pipeline is
+// stored under a new key, and FromResult references to it from exec's steps follow that key.
+// The commit and rollback hooks of exec are added to this exec.
 func (e *execQuery) Wrap(exec Exec) Exec {
 	execQuery, ok := exec.(*execQuery)
-	if !ok || execQuery == nil {
+	if !ok || execQuery == nil || execQuery == e {
 		return e
 	}
-	execQuery.query = e.pipeline.uniqueQuery(execQuery.query)
-	execQuery.pipeline.addFirstPipeline(execQuery.query, execQuery.keyValuePairs)
-	e.pipeline.appendPipeline(execQuery.pipeline)
+
+	// Snapshot the wrapped steps with its own query first, as its ExecInTx would run them
+	steps := execQuery.plan()
+	if e.err == nil {
+		e.err = execQuery.err
+	}
+	e.pipeline.appendPipeline(steps, e.postgres.resultHook, e.query)
+	e.onCommit = append(e.onCommit, execQuery.onCommit...)
+	e.onRollback = append(e.onRollback, execQuery.onRollback...)
 	return e
 }
 
@@ -122,10 +409,244 @@ func (e *execQuery) Delete(query string, keyValuePairs ...any) Exec {
 	e.pipeline.addPipeline(query, keyValuePairs)
 	return e
 }
+
+// Select adds a select query to the transaction pipeline.
+// The rows are scanned into destination inside the transaction, and the selected value
+// can be referenced by later queries with FromResult, e.g. to read a value and then update it.
 func (e *execQuery) Select(query string, destination any, keyValuePairs ...any) Exec {
-	e.pipeline.addPipeline(query, keyValuePairs)
+	e.pipeline.addSelectPipeline(query, destination, keyValuePairs)
+	return e
+}
+
+// Reset clears the pipeline, query, key-value pairs and debug flag so the
+// builder can be reused for another batch without allocating a new one.
+// It is not needed to run the same pipeline again: ExecInTx leaves the builder unchanged.
+func (e *execQuery) Reset() Exec {
+	e.query = ""
+	e.keyValuePairs = nil
+	e.debug = false
+	e.localSettings = nil
+	e.returning = nil
+	e.txOptions = nil
+	e.retryAttempts = 0
+	e.retryBackoff = 0
+	e.label = ""
+	e.optional = false
+	e.onCommit = nil
+	e.onRollback = nil
+	e.statementTimeout = 0
+	e.sharedArgs = nil
+	e.noReturn = false
+	e.allowFull = false
+	e.err = nil
+	e.pipeline.Clear()
+	return e
+}
+
+// SetLocal sets a configuration parameter for the duration of the transaction,
+// like SET LOCAL name = value. It is applied before any other statement in ExecInTx.
+// Both name and value are bound through set_config, so neither is interpolated into SQL.
+// value is a string, []byte, boolean, number, time or driver.Valuer; nil resets the parameter
+// to its default. Any other type makes ExecInTx return an error without starting the transaction.
+func (e *execQuery) SetLocal(name string, value any) Exec {
+	text, err := settingValue(value)
+	if err != nil {
+		if e.err == nil {
+			e.err = errors.Wrapf(err, "invalid value for setting %q", name)
+		}
+		return e
+	}
+	e.localSettings = append(e.localSettings, localSetting{name: name, value: text})
+	return e
+}
+
+// WithSharedArgs adds key-value pairs to the arguments of every step, e.g. a tenant_id used
+// by each query of a pipeline, so steps do not have to repeat them. A key given in a step's
+// own key-value pairs wins over a shared one. Shared values cannot reference FromResult,
+// and the shared arguments of an exec passed to Wrap are not applied.
+//
+// Example:
+//
+//	db.Insert("INSERT INTO orders (tenant_id, item) VALUES (:tenant_id, :item)", "item", "book").
+//		Insert("INSERT INTO audit_log (tenant_id, message) VALUES (:tenant_id, :message)", "message", "order created").
+//		WithSharedArgs("tenant_id", tenantID).
+//		ExecInTx(ctx)
+func (e *execQuery) WithSharedArgs(keyValuePairs ...any) Exec {
+	e.sharedArgs = append(e.sharedArgs, keyValuePairs...)
+	return e
+}
+
+// WithSchema scopes the transaction started by ExecInTx to schema, like
+// SET LOCAL search_path TO schema, so unqualified names resolve against it until the
+// transaction ends. schema must be a plain identifier of letters, digits, _ and $,
+// otherwise ExecInTx returns an error without starting the transaction.
+func (e *execQuery) WithSchema(schema string) Exec {
+	if !schemaNameRegex.MatchString(schema) {
+		if e.err == nil {
+			e.err = errors.Errorf("invalid schema name %q", schema)
+		}
+		return e
+	}
+	return e.SetLocal("search_path", quoteName(schema))
+}
+
+// WithStatementTimeout makes the server cancel any statement of the query or pipeline that runs
+// longer than timeout, like SET LOCAL statement_timeout, regardless of the deadline of ctx.
+// ExecInTx sets it for its transaction, and Exec then runs the query in its own transaction.
+// A cancelled statement returns an error matching ErrStatementTimeout.
+func (e *execQuery) WithStatementTimeout(timeout time.Duration) Exec {
+	e.statementTimeout = timeout
+	return e
+}
+
+// Returning scans the RETURNING row of the query into destination when calling Exec.
+// destination may be a pointer to a scalar for a single column or to a struct for
+// several columns, e.g. INSERT ... RETURNING id, created_at.
+// Exec then returns destination instead of the inserted ID or affected rows.
+func (e *execQuery) Returning(destination any) Exec {
+	e.returning = destination
+	return e
+}
+
+// WithIsolation sets the isolation level of the transaction started by ExecInTx,
+// e.g. sql.LevelSerializable or sql.LevelRepeatableRead.
+func (e *execQuery) WithIsolation(level sql.IsolationLevel) Exec {
+	if e.txOptions == nil {
+		e.txOptions = &sql.TxOptions{}
+	}
+	e.txOptions.Isolation = level
+	return e
+}
+
+// ReadOnly makes the transaction started by ExecInTx read-only.
+func (e *execQuery) ReadOnly() Exec {
+	if e.txOptions == nil {
+		e.txOptions = &sql.TxOptions{}
+	}
+	e.txOptions.ReadOnly = true
+	return e
+}
+
+// WithRetry makes ExecInTx re-run the whole pipeline in a fresh transaction when it fails
+// with a serialization failure (40001) or a deadlock (40P01), waiting backoff between attempts.
+// It gives up after maxAttempts attempts in total and returns the last error.
+func (e *execQuery) WithRetry(maxAttempts int, backoff time.Duration) Exec {
+	e.retryAttempts = maxAttempts
+	e.retryBackoff = backoff
+	return e
+}
+
+// As labels the most recently added query, so its result can be fetched with
+// TxResult(label) and referenced by later queries with FromResult(label),
+// independently of the query text. Labels must be unique within a pipeline.
+// A labelled query is also named by its label in ExecInTx errors.
+//
+// Example:
+//
+//	db.Insert("INSERT INTO users (name) VALUES (:name) RETURNING id", "name", "Alice").As("user").
+//		Insert("INSERT INTO profiles (user_id) VALUES (:user_id)", "user_id", db.FromResult("user")).
+//		ExecInTx(ctx)
+func (e *execQuery) As(label string) Exec {
+	if !e.pipeline.labelLast(label) {
+		e.label = label
+	}
+	return e
+}
+
+// Optional marks the most recently added query as allowed to fail in ExecInTx.
+// The query runs inside a savepoint; if it fails, the transaction is rolled back to the
+// savepoint and the remaining queries still run and commit. A failed optional query has no
+// result, so later queries referencing it with FromResult receive NULL.
+//
+// Example:
+//
+//	db.Insert("INSERT INTO orders (user_id) VALUES (:user_id)", "user_id", 1).
+//		Insert("INSERT INTO audit_log (message) VALUES (:message)", "message", "order created").Optional().
+//		ExecInTx(ctx)
+func (e *execQuery) Optional() Exec {
+	if !e.pipeline.markLastOptional() {
+		e.optional = true
+	}
+	return e
+}
+
+// OnCommit registers hook to run after the transaction started by ExecInTx commits.
+// Hooks run outside the transaction, in registration order, e.g. to publish events or invalidate caches.
+func (e *execQuery) OnCommit(hook func()) Exec {
+	e.onCommit = append(e.onCommit, hook)
+	return e
+}
+
+// NoReturn marks the most recently added INSERT as run without expecting a returned ID,
+// e.g. for a table without an auto-increment key or when the ID is not needed. The insert
+// reports its affected rows instead: Exec returns them, and in ExecInTx they are its result,
+// so FromResult on it receives the row count.
+//
+// Example:
+//
+//	db.Insert("INSERT INTO user_tags (user_id, tag) VALUES (:user_id, :tag)", "user_id", 1, "tag", "admin").
+//		NoReturn().ExecUpdate(ctx)
+func (e *execQuery) NoReturn() Exec {
+	if !e.pipeline.markLastNoReturn() {
+		e.noReturn = true
+	}
+	return e
+}
+
+// OnRollback registers hook to run after the transaction started by ExecInTx is rolled back,
+// with the error that caused the rollback. A panic in the pipeline is reported as an error
+// before the panic is re-raised. With WithRetry, hooks run after every failed attempt.
+func (e *execQuery) OnRollback(hook func(err error)) Exec {
+	e.onRollback = append(e.onRollback, hook)
 	return e
 }
+
+// AllowFullTable lets the queries of this Exec update or delete without a WHERE clause
+// when the client is configured with WithRequireWhere.
+func (e *execQuery) AllowFullTable() Exec {
+	e.allowFull = true
+	return e
+}
+
+// runCommitHooks runs the hooks registered with OnCommit.
+func (e *execQuery) runCommitHooks() {
+	for _, hook := range e.onCommit {
+		hook()
+	}
+}
+
+// runRollbackHooks runs the hooks registered with OnRollback.
+func (e *execQuery) runRollbackHooks(err error) {
+	for _, hook := range e.onRollback {
+		hook(err)
+	}
+}
+
+// set stores the result, affected rows and duration of a query under its key and its label, if any.
+func (e *ExecResult) set(query, label string, id any, rowsAffected int64, duration time.Duration) {
+	e.ids[query] = id
+	e.rowsAffected[query] = rowsAffected
+	e.durations[query] = duration
+	if label != "" {
+		e.ids[label] = id
+		e.rowsAffected[label] = rowsAffected
+		e.durations[label] = duration
+	}
+}
+
+// TxResult returns the result of a query, looked up by its query text or its label.
 func (e *ExecResult) TxResult(query string) any {
 	return e.ids[query]
 }
+
+// RowsAffected returns the number of rows the query affected in the pipeline, looked up by
+// its query text or its label. A select step reports the number of rows it returned, and
+// an insert or RETURNING step reports 1 if it returned a row.
+func (e *ExecResult) RowsAffected(query string) int64 {
+	return e.rowsAffected[query]
+}
+
+// Duration returns how long the query took to execute in the pipeline.
+func (e *ExecResult) Duration(query string) time.Duration {
+	return e.durations[query]
+}
@@ -0,0 +1,421 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// failingUpdates answers UPDATE queries with err and other queries like returningOne.
+func failingUpdates(err error) fakeHandler {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		if strings.HasPrefix(query, "UPDATE") {
+			return fakeResult{}, err
+		}
+		return returningOne(ctx, query, args)
+	}
+}
+
+// panickingValuer panics when its value is taken, e.g. to print it in debug output.
+type panickingValuer struct{}
+
+func (panickingValuer) Value() (driver.Value, error) {
+	panic("value failed")
+}
+
+// transactionHooks records the hooks of an Exec that ran.
+type transactionHooks struct {
+	commits     int
+	rollbackErr []error
+}
+
+func (h *transactionHooks) register(exec Exec) Exec {
+	return exec.
+		OnCommit(func() { h.commits++ }).
+		OnRollback(func(err error) { h.rollbackErr = append(h.rollbackErr, err) })
+}
+
+func TestOnCommitRunsAfterCommit(t *testing.T) {
+	db := newFakeDriver(nil).client(t)
+
+	hooks := &transactionHooks{}
+	exec := db.Insert("INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book").
+		Update("UPDATE stock SET count = count - 1 WHERE item = :item", "item", "book")
+	if _, err := hooks.register(exec).ExecInTx(context.Background()); err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+	if hooks.commits != 1 || len(hooks.rollbackErr) != 0 {
+		t.Errorf("hooks ran %d commits and %d rollbacks, want 1 commit", hooks.commits, len(hooks.rollbackErr))
+	}
+}
+
+func TestOnRollbackRunsWithCause(t *testing.T) {
+	updateErr := errors.New("stock is locked")
+	db := newFakeDriver(failingUpdates(updateErr)).client(t)
+
+	hooks := &transactionHooks{}
+	exec := db.Insert("INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book").
+		Update("UPDATE stock SET count = count - 1 WHERE item = :item", "item", "book")
+	_, err := hooks.register(exec).ExecInTx(context.Background())
+	if !errors.Is(err, updateErr) {
+		t.Fatalf("ExecInTx = %v, want the update error", err)
+	}
+	if hooks.commits != 0 || len(hooks.rollbackErr) != 1 || !errors.Is(hooks.rollbackErr[0], updateErr) {
+		t.Errorf("hooks ran %d commits and rollbacks with %v, want one rollback with the update error", hooks.commits, hooks.rollbackErr)
+	}
+}
+
+func TestOnRollbackRunsBeforePanicIsRaised(t *testing.T) {
+	db := newFakeDriver(nil).client(t)
+
+	// The second step panics while it is printed, after the first step ran in the transaction
+	hooks := &transactionHooks{}
+	exec := db.Insert("INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book").
+		Update("UPDATE stock SET count = count - 1 WHERE item = :item", "item", panickingValuer{}).
+		Debug()
+	captureStdout(t, func() {
+		defer func() {
+			if recovered := recover(); recovered != "value failed" {
+				t.Errorf("recovered %v, want the panic re-raised", recovered)
+			}
+		}()
+		_, _ = hooks.register(exec).ExecInTx(context.Background())
+	})
+
+	if hooks.commits != 0 || len(hooks.rollbackErr) != 1 || !strings.Contains(hooks.rollbackErr[0].Error(), "transaction panicked: value failed") {
+		t.Errorf("hooks ran %d commits and rollbacks with %v, want one rollback with the panic", hooks.commits, hooks.rollbackErr)
+	}
+}
+
+func TestRollbackFailureKeepsCauseFirst(t *testing.T) {
+	updateErr := errors.New("stock is locked")
+	rollbackErr := errors.New("connection lost during rollback")
+	fake := newFakeDriver(failingUpdates(updateErr))
+	fake.rollbackErr = rollbackErr
+	db := fake.client(t)
+
+	_, err := db.Insert("INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book").
+		Update("UPDATE stock SET count = count - 1 WHERE item = :item", "item", "book").
+		ExecInTx(context.Background())
+	if !errors.Is(err, updateErr) || !errors.Is(err, rollbackErr) {
+		t.Fatalf("ExecInTx = %v, want both the update and the rollback error", err)
+	}
+	message := err.Error()
+	if cause, rollback := strings.Index(message, updateErr.Error()), strings.Index(message, "rollback failed: "+rollbackErr.Error()); cause < 0 || rollback < cause {
+		t.Errorf("ExecInTx = %q, want the update error before the rollback error", message)
+	}
+}
+
+func TestJoinRollbackErrorDropsTxDone(t *testing.T) {
+	cause := errors.New("insert failed")
+	if err := joinRollbackError(cause, nil); err != cause {
+		t.Errorf("joinRollbackError(cause, nil) = %v, want the cause", err)
+	}
+	if err := joinRollbackError(cause, sql.ErrTxDone); err != cause {
+		t.Errorf("joinRollbackError(cause, ErrTxDone) = %v, want the cause", err)
+	}
+}
+
+func TestRequireWhereBlocksFullTable(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t, WithRequireWhere(true))
+	ctx := context.Background()
+
+	blocked := map[string]func() error{
+		"update": func() error {
+			_, err := db.Update("UPDATE users SET active = :active", "active", false).ExecUpdate(ctx)
+			return err
+		},
+		"delete": func() error {
+			_, err := db.Delete("DELETE FROM users").ExecUpdate(ctx)
+			return err
+		},
+		"pipeline": func() error {
+			_, err := db.Insert("INSERT INTO audit_log (message) VALUES (:message) RETURNING id", "message", "purge").
+				Delete("DELETE FROM sessions").
+				ExecInTx(ctx)
+			return err
+		},
+		"where in a subquery only": func() error {
+			_, err := db.Delete("DELETE FROM users USING (SELECT id FROM banned WHERE active) AS b").ExecUpdate(ctx)
+			return err
+		},
+	}
+	for name, run := range blocked {
+		if err := run(); !errors.Is(err, ErrMissingWhere) {
+			t.Errorf("%s = %v, want ErrMissingWhere", name, err)
+		}
+	}
+	if queries := fake.queries.Load(); queries != 0 {
+		t.Errorf("%d queries ran, want none", queries)
+	}
+
+	if _, err := db.Delete("DELETE FROM sessions").AllowFullTable().ExecUpdate(ctx); err != nil {
+		t.Errorf("delete with AllowFullTable = %v", err)
+	}
+	if _, err := db.Update("UPDATE users SET active = :active WHERE id = :id", "active", false, "id", 1).ExecUpdate(ctx); err != nil {
+		t.Errorf("update with a WHERE clause = %v", err)
+	}
+	if queries := fake.queries.Load(); queries != 2 {
+		t.Errorf("%d queries ran, want the 2 allowed ones", queries)
+	}
+}
+
+func TestWithSchemaScopesConcurrentTransactions(t *testing.T) {
+	server := newSchemaServer()
+	db := newFakeDriver(server.handle).client(t, WithMaxOpenConns(2))
+	ctx := context.Background()
+	for _, table := range []string{"tenant_a.widgets", "tenant_b.widgets"} {
+		if _, err := db.DB().ExecContext(ctx, "CREATE TABLE "+table); err != nil {
+			t.Fatalf("CREATE TABLE %s: %v", table, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, tenant := range []string{"tenant_a", "tenant_b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				var schema string
+				_, err := db.Update("UPDATE widgets SET seen = true WHERE id = :id", "id", 1).
+					WithSchema(tenant).
+					Select("SELECT schema FROM widgets", &schema).
+					ExecInTx(ctx)
+				if err != nil || schema != tenant {
+					t.Errorf("transaction for %s resolved widgets to %q, %v", tenant, schema, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The schema is local to each transaction, so the connections are back to no search path
+	var schema string
+	if err := db.Scalar(ctx, "SELECT schema FROM widgets", &schema); err == nil {
+		t.Errorf("SELECT FROM widgets after the transactions resolved to %q, want the schema reset", schema)
+	}
+}
+
+func TestWithSchemaRejectsInvalidNames(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t)
+	for _, schema := range []string{"", "tenant; DROP TABLE users", `tenant"`, "1tenant", "public, pg_catalog"} {
+		_, err := db.Update("UPDATE widgets SET seen = true WHERE id = :id", "id", 1).WithSchema(schema).ExecInTx(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "invalid schema name") {
+			t.Errorf("WithSchema(%q) = %v, want an invalid schema name error", schema, err)
+		}
+	}
+	if queries := fake.queries.Load(); queries != 0 {
+		t.Errorf("%d queries ran, want none", queries)
+	}
+}
+
+// threeRows answers queries with a RETURNING clause with an id of 42 and others with three
+// affected rows.
+func threeRows(_ context.Context, query string, _ []driver.NamedValue) (fakeResult, error) {
+	if strings.Contains(query, "RETURNING") {
+		return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(42)}}, rowsAffected: 1}, nil
+	}
+	return fakeResult{rowsAffected: 3}, nil
+}
+
+func TestExecTypedResults(t *testing.T) {
+	fake := newFakeDriver(threeRows)
+	db := fake.client(t)
+	ctx := context.Background()
+	const insert = "INSERT INTO users (name) VALUES (:name) RETURNING id"
+
+	if id, err := db.Insert(insert, "name", "Alice").ExecInsert(ctx); err != nil || id != int64(42) {
+		t.Errorf("ExecInsert = %v, %v, want id 42", id, err)
+	}
+	for name, exec := range map[string]Exec{
+		"update": db.Update("UPDATE users SET active = :active WHERE team = :team", "active", false, "team", 1),
+		"delete": db.Delete("DELETE FROM users WHERE team = :team", "team", 1),
+	} {
+		if rows, err := exec.ExecUpdate(ctx); err != nil || rows != 3 {
+			t.Errorf("ExecUpdate on %s = %d, %v, want 3 rows", name, rows, err)
+		}
+	}
+	if rows, err := db.Insert(insert, "name", "Alice").ExecUpdate(ctx); err != nil || rows != 1 {
+		t.Errorf("ExecUpdate on an insert = %d, %v, want 1 row", rows, err)
+	}
+
+	queries := fake.queries.Load()
+	if _, err := db.Update("UPDATE users SET active = :active", "active", false).ExecInsert(ctx); err == nil || !strings.Contains(err.Error(), "use ExecUpdate instead") {
+		t.Errorf("ExecInsert on an update = %v, want an error pointing to ExecUpdate", err)
+	}
+	if fake.queries.Load() != queries {
+		t.Error("ExecInsert ran an update")
+	}
+
+	// The deprecated Exec keeps returning an id for inserts and a row count for the rest
+	if id, err := db.Insert(insert, "name", "Alice").Exec(ctx); err != nil || id != int64(42) {
+		t.Errorf("Exec on an insert = %v, %v, want id 42", id, err)
+	}
+	if rows, err := db.Delete("DELETE FROM users WHERE team = :team", "team", 1).Exec(ctx); err != nil || rows != int64(3) {
+		t.Errorf("Exec on a delete = %v, %v, want 3 rows", rows, err)
+	}
+}
+
+// sequenceServer answers queries with a RETURNING clause with increasing ids and records every
+// query with its arguments in order.
+type sequenceServer struct {
+	mu      sync.Mutex
+	lastID  int64
+	queries []string
+	args    [][]any
+}
+
+func (s *sequenceServer) handle(_ context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	s.queries = append(s.queries, query)
+	s.args = append(s.args, values)
+	if strings.Contains(query, "RETURNING") {
+		s.lastID++
+		return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{s.lastID}}, rowsAffected: 1}, nil
+	}
+	return fakeResult{rowsAffected: 1}, nil
+}
+
+func TestWrapRunsStepsInCallOrder(t *testing.T) {
+	const (
+		insertOrder = "INSERT INTO orders (item) VALUES (:item) RETURNING id"
+		insertLine  = "INSERT INTO order_lines (order_id, item) VALUES (:order_id, :item) RETURNING id"
+		insertAudit = "INSERT INTO audit_log (order_id) VALUES (:order_id)"
+	)
+	for range 10 {
+		server := &sequenceServer{}
+		db := newFakeDriver(server.handle).client(t)
+
+		// Both execs use the same query texts, and each FromResult must bind its own exec's order
+		first := db.Insert(insertOrder, "item", "book")
+		first.Insert(insertLine, "order_id", first.FromResult(insertOrder), "item", "book")
+		second := db.Insert(insertOrder, "item", "pen")
+		second.Insert(insertLine, "order_id", second.FromResult(insertOrder), "item", "pen")
+
+		exec := first.Wrap(second)
+		exec.Insert(insertAudit, "order_id", exec.FromResult(insertOrder)).NoReturn()
+		if _, err := exec.ExecInTx(context.Background()); err != nil {
+			t.Fatalf("ExecInTx: %v", err)
+		}
+
+		wantArgs := [][]any{{"book"}, {int64(1), "book"}, {"pen"}, {int64(3), "pen"}, {int64(1)}}
+		if len(server.args) != len(wantArgs) {
+			t.Fatalf("ran %q, want %d queries", server.queries, len(wantArgs))
+		}
+		for i, want := range wantArgs {
+			if !reflect.DeepEqual(server.args[i], want) {
+				t.Errorf("query %d %q bound %v, want %v", i, server.queries[i], server.args[i], want)
+			}
+		}
+	}
+}
+
+func TestPipelineErrorNamesStep(t *testing.T) {
+	updateErr := errors.New("stock is locked")
+	db := newFakeDriver(failingUpdates(updateErr)).client(t)
+
+	_, err := db.Insert("INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book").As("create_order").
+		Update("UPDATE stock SET count = count - 1 WHERE item = :item AND warehouse_id = :warehouse_id AND count > 0", "item", "book", "warehouse_id", 3).As("reserve_stock").
+		ExecInTx(context.Background())
+	if !errors.Is(err, updateErr) {
+		t.Fatalf("ExecInTx = %v, want the update error", err)
+	}
+	if want := `query at index 1 "reserve_stock" (UPDATE stock SET count = count - 1 WHERE item = :item AND wa...)`; !strings.Contains(err.Error(), want) {
+		t.Errorf("ExecInTx = %q, want it to name the step as %s", err, want)
+	}
+
+	_, err = db.Insert("INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book").
+		Update("UPDATE stock SET count = count - 1 WHERE item = :item", "item", "book").
+		ExecInTx(context.Background())
+	if want := "query at index 1 (UPDATE stock SET count = count - 1 WHERE item = :item)"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("ExecInTx = %v, want it to name the unlabeled step as %s", err, want)
+	}
+}
+
+func TestSharedArgsApplyToEveryStep(t *testing.T) {
+	server := &sequenceServer{}
+	db := newFakeDriver(server.handle).client(t)
+
+	_, err := db.Insert("INSERT INTO orders (item, tenant_id) VALUES (:item, :tenant_id) RETURNING id", "item", "book").
+		Update("UPDATE stock SET count = count - 1 WHERE item = :item AND tenant_id = :tenant_id", "item", "book").
+		Delete("DELETE FROM carts WHERE tenant_id = :tenant_id AND owner = :owner", "owner", "alice", "tenant_id", 9).
+		WithSharedArgs("tenant_id", 7, "unused", true).
+		ExecInTx(context.Background())
+	if err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+
+	// The delete declares its own tenant_id, which wins over the shared one
+	wantArgs := [][]any{{"book", int64(7)}, {"book", int64(7)}, {int64(9), "alice"}}
+	if !reflect.DeepEqual(server.args, wantArgs) {
+		t.Errorf("steps bound %v, want %v", server.args, wantArgs)
+	}
+}
+
+func TestRowsAffectedPerStep(t *testing.T) {
+	db := newFakeDriver(threeRows).client(t)
+
+	const (
+		insert = "INSERT INTO orders (item) VALUES (:item) RETURNING id"
+		update = "UPDATE stock SET reserved = true WHERE item = :item"
+	)
+	result, err := db.Insert(insert, "item", "book").
+		Update(update, "item", "book").As("reserve").
+		ExecInTx(context.Background())
+	if err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+	if rows := result.RowsAffected(update); rows != 3 {
+		t.Errorf("RowsAffected(update) = %d, want 3", rows)
+	}
+	if rows := result.RowsAffected("reserve"); rows != 3 {
+		t.Errorf("RowsAffected by label = %d, want 3", rows)
+	}
+	if rows := result.RowsAffected(insert); rows != 1 {
+		t.Errorf("RowsAffected(insert) = %d, want 1", rows)
+	}
+	if rows := result.RowsAffected("DELETE FROM carts"); rows != 0 {
+		t.Errorf("RowsAffected of a query not in the pipeline = %d, want 0", rows)
+	}
+}
+
+func TestNoReturnInsertsIntoKeylessTable(t *testing.T) {
+	recorder := &queryRecorder{rowsAffected: 2}
+	db := newFakeDriver(recorder.handle).client(t, WithMaxOpenConns(1))
+	ctx := context.Background()
+	const insertTags = "INSERT INTO user_tags (user_id, tag) VALUES (:user_id, 'admin'), (:user_id, 'staff')"
+
+	if rows, err := db.Insert(insertTags, "user_id", 1).NoReturn().ExecUpdate(ctx); err != nil || rows != 2 {
+		t.Errorf("ExecUpdate = %d, %v, want 2 rows", rows, err)
+	}
+	if rows, err := db.Insert(insertTags, "user_id", 1).NoReturn().Exec(ctx); err != nil || rows != int64(2) {
+		t.Errorf("Exec = %v, %v, want 2 rows", rows, err)
+	}
+
+	const insertAudit = "INSERT INTO audit_log (tags_added) VALUES (:tags_added)"
+	result, err := db.Insert(insertTags, "user_id", 1).NoReturn().
+		Insert(insertAudit, "tags_added", db.FromResult(insertTags)).NoReturn().
+		ExecInTx(ctx)
+	if err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+	if got := result.TxResult(insertTags); got != int64(2) {
+		t.Errorf("TxResult = %v, want the 2 inserted rows", got)
+	}
+	if last := recorder.args[len(recorder.args)-1]; !reflect.DeepEqual(last, []any{int64(2)}) {
+		t.Errorf("audit insert bound %v, want the row count of the tags insert", last)
+	}
+}
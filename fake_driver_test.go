@@ -0,0 +1,309 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeDriverName is the name the fake driver is registered under, for tests that go through New.
+const fakeDriverName = "postgres-fake"
+
+// fakeDrivers maps the dsn passed to the registered fake driver to the fakeDriver serving it.
+var fakeDrivers sync.Map
+
+func init() {
+	sql.Register(fakeDriverName, fakeRouter{})
+}
+
+// fakeRouter is the registered driver. It hands every Open to the fakeDriver registered for
+// the dsn's host, so tests going through New can share one registration.
+type fakeRouter struct{}
+
+func (fakeRouter) Open(dsn string) (driver.Conn, error) {
+	var found *fakeDriver
+	fakeDrivers.Range(func(key, value any) bool {
+		if slices.Contains(strings.Fields(dsn), "host="+key.(string)) {
+			found = value.(*fakeDriver)
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return nil, driver.ErrBadConn
+	}
+	return found.Open(dsn)
+}
+
+// fakeResult is the answer of a fake query.
+type fakeResult struct {
+	columns      []string
+	rows         [][]driver.Value
+	rowsErr      error // Returned by Next after the rows, e.g. to break a connection mid-result
+	rowsAffected int64
+}
+
+// fakeHandler answers a query sent to the fake driver.
+type fakeHandler func(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error)
+
+// fakeDriver is a database/sql driver that answers queries from a handler instead of a server.
+// It counts the connections, prepares and statements it serves.
+type fakeDriver struct {
+	handler     fakeHandler
+	rollbackErr error // Returned by every transaction rollback, if set
+	opens       atomic.Int64
+	prepares    atomic.Int64
+	closes      atomic.Int64 // Closed statements
+	queries     atomic.Int64
+	commits     atomic.Int64
+	rollbacks   atomic.Int64
+
+	mu   sync.Mutex
+	dsns []string
+}
+
+// newFakeDriver creates a fake driver answering queries with handler, or with returningOne if nil.
+func newFakeDriver(handler fakeHandler) *fakeDriver {
+	if handler == nil {
+		handler = returningOne
+	}
+	return &fakeDriver{handler: handler}
+}
+
+// returningOne answers a query with a RETURNING clause with an id of 1, and any other query
+// with one affected row and no result rows.
+func returningOne(_ context.Context, query string, _ []driver.NamedValue) (fakeResult, error) {
+	if strings.Contains(query, "RETURNING") {
+		return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}, rowsAffected: 1}, nil
+	}
+	return fakeResult{rowsAffected: 1}, nil
+}
+
+// register makes the fake driver serve dsns with host=host through fakeDriverName.
+func (d *fakeDriver) register(t testing.TB, host string) {
+	fakeDrivers.Store(host, d)
+	t.Cleanup(func() { fakeDrivers.Delete(host) })
+}
+
+// db opens a pool on the fake driver, closed when the test ends.
+func (d *fakeDriver) db(t testing.TB) *sqlx.DB {
+	database := sqlx.NewDb(sql.OpenDB(fakeConnector{driver: d}), "postgres")
+	t.Cleanup(func() { _ = database.Close() })
+	return database
+}
+
+// client creates a client on a pool of the fake driver.
+func (d *fakeDriver) client(t testing.TB, opts ...Option) *postgres {
+	t.Helper()
+	client, err := NewWithDB(d.db(t), opts...)
+	if err != nil {
+		t.Fatalf("NewWithDB: %v", err)
+	}
+	return client.(*postgres)
+}
+
+// openedDsns returns the dsns connections were opened with, in order.
+func (d *fakeDriver) openedDsns() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.dsns...)
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.opens.Add(1)
+	d.mu.Lock()
+	d.dsns = append(d.dsns, dsn)
+	d.mu.Unlock()
+	return &fakeConn{driver: d, session: fakeSession{settings: map[string]string{}, local: map[string]string{}}}, nil
+}
+
+// fakeConnector opens fake connections without a registered driver name.
+type fakeConnector struct {
+	driver *fakeDriver
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open("")
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// fakeConn is a connection of the fake driver. Transactions are counted and their options kept
+// as local settings of the session, which end with the transaction. Nothing is rolled back,
+// and rollbacks fail with the driver's rollbackErr.
+type fakeConn struct {
+	driver  *fakeDriver
+	session fakeSession
+	closed  bool
+}
+
+// fakeSession is the state of a fake connection that handlers can keep, such as settings.
+// A connection is used by one query at a time, so handlers need not lock it.
+type fakeSession struct {
+	settings map[string]string // Set for the session, e.g. by SET
+	local    map[string]string // Set until the end of the transaction, e.g. by SET LOCAL
+}
+
+// setting returns the value of the setting name, preferring a transaction-local value.
+func (s *fakeSession) setting(name string) string {
+	if value, ok := s.local[name]; ok {
+		return value
+	}
+	return s.settings[name]
+}
+
+// fakeSessionKey is the context key of the session of the connection a handler answers for.
+type fakeSessionKey struct{}
+
+// fakeSessionFrom returns the session of the connection running the query of ctx.
+func fakeSessionFrom(ctx context.Context) *fakeSession {
+	return ctx.Value(fakeSessionKey{}).(*fakeSession)
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *fakeConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	c.driver.prepares.Add(1)
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) BeginTx(_ context.Context, options driver.TxOptions) (driver.Tx, error) {
+	c.session.local["transaction_isolation"] = sql.IsolationLevel(options.Isolation).String()
+	if options.ReadOnly {
+		c.session.local["transaction_read_only"] = "on"
+	}
+	return fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) Ping(context.Context) error {
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.exec(ctx, query, args)
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.query(ctx, query, args)
+}
+
+func (c *fakeConn) exec(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.queries.Add(1)
+	result, err := c.driver.handler(context.WithValue(ctx, fakeSessionKey{}, &c.session), query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.rowsAffected), nil
+}
+
+func (c *fakeConn) query(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.queries.Add(1)
+	result, err := c.driver.handler(context.WithValue(ctx, fakeSessionKey{}, &c.session), query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{result: result}, nil
+}
+
+// fakeStmt is a prepared statement of the fake driver.
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	s.conn.driver.closes.Add(1)
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.exec(ctx, s.query, args)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.query(ctx, s.query, args)
+}
+
+// namedValues converts positional driver values to named values.
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, value := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: value}
+	}
+	return named
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (t fakeTx) Commit() error {
+	t.conn.driver.commits.Add(1)
+	clear(t.conn.session.local)
+	return nil
+}
+
+func (t fakeTx) Rollback() error {
+	t.conn.driver.rollbacks.Add(1)
+	clear(t.conn.session.local)
+	return t.conn.driver.rollbackErr
+}
+
+// fakeRows iterates over the rows of a fakeResult.
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.result.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		if r.result.rowsErr != nil {
+			return r.result.rowsErr
+		}
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
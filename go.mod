@@ -3,17 +3,26 @@ module github.com/andryhardiyanto/go-postgres
 go 1.26.2
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/andryhardiyanto/go-async v1.1.0
 	github.com/golang-migrate/migrate/v4 v4.18.3
+	github.com/jackc/pgx/v5 v5.7.2
 	github.com/jmoiron/sqlx v1.4.0
+	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	github.com/newrelic/go-agent/v3/integrations/nrpq v1.1.1
 	github.com/pkg/errors v0.9.1
+	go.opentelemetry.io/otel v1.46.0
+	go.opentelemetry.io/otel/trace v1.46.0
 )
 
 require (
-	github.com/andryhardiyanto/go-async v1.1.0 // indirect
-	github.com/andryhardiyanto/go-async/v2 v2.0.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/newrelic/go-agent/v3 v3.3.0 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/sync v0.20.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
 )
@@ -5,22 +5,41 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
-	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
+// Array types scan Postgres arrays and bind Go slices as arrays. Use them as struct field types,
+// e.g. Tags StringSlice `db:"tags"`, so text[], bigint[] and timestamptz[] columns scan in One, Many and Rows;
+// plain []string and []int64 fields cannot be scanned by database/sql.
 type (
+	// StringSlice is a text array.
 	StringSlice []string
+
+	// IntSlice is an integer array.
+	IntSlice []int64
+
+	// NullableStringSlice is a text array whose elements may be NULL, represented as nil.
+	NullableStringSlice []*string
+
+	// TimeSlice is a timestamptz or timestamp array.
+	TimeSlice []time.Time
 )
 
 var (
-	quoteEscapeRegex = regexp.MustCompile(`([^\\]([\\]{2})*)\\"`)
+	schemaNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]{0,62}$`)
+	// inClauseOpenRegex and inClauseCloseRegex match the text around the placeholder of IN (:key)
+	inClauseOpenRegex  = regexp.MustCompile(`(?i)\b(NOT\s+)?IN\s*\(\s*$`)
+	inClauseCloseRegex = regexp.MustCompile(`^\s*\)`)
 )
 
 const (
@@ -28,67 +47,279 @@ const (
 	qUpdate = "update"
 	qDelete = "delete"
 	qSelect = "select"
+	qMerge  = "merge"
 
 	qResult = "q-result---"
+
+	driverPgx = "pgx"
+
+	// generatedKeyPrefix starts the keys of the parameters the package adds to a query, such as
+	// the elements of an expanded IN clause, so they cannot collide with the caller's keys.
+	generatedKeyPrefix = "__"
 )
 
-func debugQuery(query string, arguments map[string]any) {
-	// Replace parameters in query
-	finalQuery := query
-	for key, value := range arguments {
-		finalQuery = strings.ReplaceAll(finalQuery, ":"+key, fmt.Sprintf("'%v'", value))
+// debugQuery prints query with every :name parameter replaced by its argument. Parameters are
+// matched as whole names, so :id never replaces the start of :id_long.
+func debugQuery(query string, arguments map[string]any, fields map[string]any) {
+	// Replace parameters in query, leaving :: casts, literals and comments untouched
+	var finalQuery strings.Builder
+	position := 0
+	for _, parameter := range namedParameters(query) {
+		value, exists := arguments[parameter.name]
+		if !exists {
+			continue
+		}
+		finalQuery.WriteString(query[position:parameter.start])
+		finalQuery.WriteString(debugValue(value))
+		position = parameter.end
 	}
+	finalQuery.WriteString(query[position:])
 
-	fmt.Println("[DEBUG SQL]", finalQuery)
+	fmt.Println("[DEBUG SQL]", formatFields(fields)+finalQuery.String())
 }
 
-func queryType(query string) string {
-	query = strings.TrimSpace(query)
-	if len(query) < 6 {
-		return qSelect // Default to select for short queries
+// debugValue formats value as an SQL literal for debug output. nil is NULL, numbers and
+// bools are unquoted and anything else is quoted with embedded single quotes doubled.
+// A driver.Valuer, such as StringSlice, is shown as the value it sends to the driver.
+// The result is only meant to be read, it is never executed.
+func debugValue(value any) string {
+	if value == nil {
+		return "NULL"
 	}
 
-	queryPrefix := strings.ToLower(query[:6])
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() == reflect.Pointer && reflected.IsNil() {
+		return "NULL"
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		driverValue, err := valuer.Value()
+		if err != nil {
+			return fmt.Sprintf("<invalid %T: %v>", value, err)
+		}
+		if _, nested := driverValue.(driver.Valuer); nested {
+			return fmt.Sprintf("%v", driverValue)
+		}
+		return debugValue(driverValue)
+	}
 
-	switch {
-	case strings.HasPrefix(queryPrefix, qInsert):
-		return qInsert
-	case strings.HasPrefix(queryPrefix, qUpdate):
-		return qUpdate
-	case strings.HasPrefix(queryPrefix, qDelete):
-		return qDelete
+	for reflected.Kind() == reflect.Pointer {
+		if reflected.IsNil() {
+			return "NULL"
+		}
+		reflected = reflected.Elem()
+	}
+
+	switch reflected.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%v", reflected.Interface())
+	}
+
+	var text string
+	switch typed := reflected.Interface().(type) {
+	case time.Time:
+		text = typed.Format(time.RFC3339Nano)
+	case []byte:
+		text = string(typed)
+	default:
+		text = fmt.Sprintf("%v", typed)
+	}
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
+
+// queryType returns the kind of statement query is: insert, update, delete, merge or select.
+// Leading whitespace and comments are skipped. For a WITH query the statement that follows
+// the common table expressions decides the type, so WITH ... INSERT is an insert.
+func queryType(query string) string {
+	word, position := nextTopLevelWord(query, 0)
+	if word == "with" {
+		// CTE bodies are parenthesized, so the first known keyword outside them is the statement
+		for word != "" {
+			word, position = nextTopLevelWord(query, position)
+			switch word {
+			case qInsert, qUpdate, qDelete, qMerge, qSelect:
+				return word
+			}
+		}
+		return qSelect
+	}
+
+	switch word {
+	case qInsert, qUpdate, qDelete, qMerge:
+		return word
 	default:
 		return qSelect
 	}
 }
 
+// missesWhere returns true if query is an UPDATE or DELETE without a top-level WHERE clause.
+// A WHERE inside a subquery or common table expression does not restrict the statement.
+func missesWhere(query string) bool {
+	switch queryType(query) {
+	case qUpdate, qDelete:
+	default:
+		return false
+	}
+
+	for word, position := nextTopLevelWord(query, 0); word != ""; word, position = nextTopLevelWord(query, position) {
+		if word == "where" {
+			return false
+		}
+	}
+	return true
+}
+
+// nextTopLevelWord returns the next lower-cased word of query starting at position that is
+// outside parentheses, string literals, quoted identifiers, dollar-quoted strings and comments,
+// and the position after it.
+// It returns an empty word at the end of the query.
+func nextTopLevelWord(query string, position int) (string, int) {
+	depth := 0
+	for position < len(query) {
+		switch character := query[position]; {
+		case strings.HasPrefix(query[position:], "--"):
+			end := strings.IndexByte(query[position:], '\n')
+			if end < 0 {
+				return "", len(query)
+			}
+			position += end + 1
+		case strings.HasPrefix(query[position:], "/*"):
+			position = skipBlockComment(query, position)
+		case character == '\'':
+			position = skipQuoted(query, position, isEscapeString(query, position))
+		case character == '"':
+			position = skipQuoted(query, position, false)
+		case character == '$':
+			position = skipDollarQuoted(query, position)
+		case character == '(':
+			depth++
+			position++
+		case character == ')':
+			depth--
+			position++
+		case depth == 0 && isWordCharacter(character):
+			start := position
+			for position < len(query) && isWordCharacter(query[position]) {
+				position++
+			}
+			return strings.ToLower(query[start:position]), position
+		default:
+			position++
+		}
+	}
+	return "", position
+}
+
+// isWordCharacter returns true if character can be part of an SQL keyword or identifier.
+func isWordCharacter(character byte) bool {
+	return character == '_' || character >= 'a' && character <= 'z' || character >= 'A' && character <= 'Z' || character >= '0' && character <= '9'
+}
+
+// txStatements prepares the named statements run in a transaction. Unless statements are
+// reused, each is prepared for a single use and closed by its release func.
+type txStatements struct {
+	tx       *sqlx.Tx
+	prepared map[string]*sqlx.NamedStmt // Reused statements by query, nil when statements are not reused
+}
+
+// newReusedStatements creates txStatements that prepare every distinct query once and keep
+// the statement until close. Queries that differ only in the suffix added by uniqueQuery,
+// such as repeated inserts of a pipeline, share one statement.
+func newReusedStatements(tx *sqlx.Tx) *txStatements {
+	return &txStatements{tx: tx, prepared: make(map[string]*sqlx.NamedStmt)}
+}
+
+// prepare returns the prepared statement for query and the func to call once it is no longer used.
+func (s *txStatements) prepare(ctx context.Context, query string) (*sqlx.NamedStmt, func(), error) {
+	if s.prepared == nil {
+		preparedStatement, err := s.tx.PrepareNamedContext(ctx, escapeNamedQuery(query))
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return preparedStatement, func() { _ = preparedStatement.Close() }, nil
+	}
+
+	query = uniqueSuffixRegex.ReplaceAllString(query, "")
+	if preparedStatement, exists := s.prepared[query]; exists {
+		return preparedStatement, func() {}, nil
+	}
+	preparedStatement, err := s.tx.PrepareNamedContext(ctx, escapeNamedQuery(query))
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	s.prepared[query] = preparedStatement
+	return preparedStatement, func() {}, nil
+}
+
+// close closes the reused statements.
+func (s *txStatements) close() {
+	for query, preparedStatement := range s.prepared {
+		_ = preparedStatement.Close()
+		delete(s.prepared, query)
+	}
+}
+
 // insertTx inserts data into the database using a transaction
 // and returns the inserted ID
-func insertTx(ctx context.Context, transaction *sqlx.Tx, query string, arguments map[string]any) (any, error) {
+func insertTx(ctx context.Context, statements *txStatements, query string, arguments map[string]any) (any, error) {
 	var insertedID any
-	preparedStatement, err := transaction.PrepareNamedContext(ctx, query)
+	preparedStatement, release, err := statements.prepare(ctx, query)
 	if err != nil {
 		return 0, err
 	}
-	defer preparedStatement.Close()
+	defer release()
 
 	err = preparedStatement.GetContext(ctx, &insertedID, arguments)
 	if err != nil {
-		return "", err
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, noReturnedIDError(err)
+		}
+		return 0, errors.WithStack(err)
 	}
 	if insertedID == nil {
-		return "", errors.WithStack(errors.New("insert operation failed: no ID was returned from the database. This may indicate that the table does not have an auto-increment primary key or the insert did not complete successfully"))
+		return 0, noReturnedIDError(nil)
 	}
 	return insertedID, nil
 }
 
+// hasReturning returns true if the query has a top-level RETURNING clause. The word inside
+// string literals, quoted identifiers, comments and subqueries does not count.
+func hasReturning(query string) bool {
+	for word, position := nextTopLevelWord(query, 0); word != ""; word, position = nextTopLevelWord(query, position) {
+		if word == "returning" {
+			return true
+		}
+	}
+	return false
+}
+
+// returningTx executes an update or delete with a RETURNING clause using a transaction
+// and returns the first returned value, or nil if no row matched.
+func returningTx(ctx context.Context, statements *txStatements, query string, arguments map[string]any) (any, error) {
+	var returnedValue any
+	preparedStatement, release, err := statements.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	err = preparedStatement.GetContext(ctx, &returnedValue, arguments)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	return returnedValue, nil
+}
+
 // updateTx updates data in the database using a transaction
-func updateTx(ctx context.Context, transaction *sqlx.Tx, query string, arguments map[string]any) (int64, error) {
-	preparedStatement, err := transaction.PrepareNamedContext(ctx, query)
+func updateTx(ctx context.Context, statements *txStatements, query string, arguments map[string]any) (int64, error) {
+	preparedStatement, release, err := statements.prepare(ctx, query)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, err
 	}
-	defer preparedStatement.Close()
+	defer release()
 
 	result, err := preparedStatement.ExecContext(ctx, arguments)
 	if err != nil {
@@ -97,7 +328,7 @@ func updateTx(ctx context.Context, transaction *sqlx.Tx, query string, arguments
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return 0, nil
 		}
 		return 0, errors.WithStack(err)
@@ -107,12 +338,12 @@ func updateTx(ctx context.Context, transaction *sqlx.Tx, query string, arguments
 }
 
 // deleteTx deletes data from the database using a transaction
-func deleteTx(ctx context.Context, transaction *sqlx.Tx, query string, arguments map[string]any) (int64, error) {
-	preparedStatement, err := transaction.PrepareNamedContext(ctx, query)
+func deleteTx(ctx context.Context, statements *txStatements, query string, arguments map[string]any) (int64, error) {
+	preparedStatement, release, err := statements.prepare(ctx, query)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, err
 	}
-	defer preparedStatement.Close()
+	defer release()
 
 	result, err := preparedStatement.ExecContext(ctx, arguments)
 	if err != nil {
@@ -121,7 +352,7 @@ func deleteTx(ctx context.Context, transaction *sqlx.Tx, query string, arguments
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return 0, nil
 		}
 		return 0, errors.WithStack(err)
@@ -130,35 +361,188 @@ func deleteTx(ctx context.Context, transaction *sqlx.Tx, query string, arguments
 	return rowsAffected, nil
 }
 
+// selectTx selects data into destination using a transaction
+// and returns the selected value so later queries can reference it.
+// A pointer to a slice selects many rows; anything else selects a single row.
+func selectTx(ctx context.Context, statements *txStatements, query string, arguments map[string]any, destination any) (any, error) {
+	preparedStatement, release, err := statements.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	destinationValue := reflect.ValueOf(destination)
+	if destinationValue.Kind() != reflect.Pointer || destinationValue.IsNil() {
+		return nil, errors.Errorf("select destination must be a non-nil pointer, got %T", destination)
+	}
+
+	if destinationValue.Elem().Kind() == reflect.Slice {
+		err = preparedStatement.SelectContext(ctx, destination, arguments)
+	} else {
+		err = preparedStatement.GetContext(ctx, destination, arguments)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	return destinationValue.Elem().Interface(), nil
+}
+
+// setLocalQuery returns the set_config query and arguments that set name to value for the transaction.
+// value is the text returned by settingValue, or nil to reset the parameter.
+func setLocalQuery(name string, value any) (string, map[string]any) {
+	return "SELECT set_config(:name, :value, true)", map[string]any{
+		"name":  name,
+		"value": value,
+	}
+}
+
+// settingValue converts value to the text set_config takes. nil and nil pointers become NULL,
+// which resets the parameter, and driver.Valuer values are converted first. Strings, []byte,
+// booleans and numbers are taken as text and times are formatted as RFC 3339; other types are rejected.
+func settingValue(value any) (any, error) {
+	reflected := reflect.ValueOf(value)
+	if value == nil || reflected.Kind() == reflect.Pointer && reflected.IsNil() {
+		return nil, nil
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		driverValue, err := valuer.Value()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if _, nested := driverValue.(driver.Valuer); nested {
+			return nil, errors.Errorf("unsupported setting value of type %T", value)
+		}
+		return settingValue(driverValue)
+	}
+
+	for reflected.Kind() == reflect.Pointer {
+		if reflected.IsNil() {
+			return nil, nil
+		}
+		reflected = reflected.Elem()
+	}
+	if typed, ok := reflected.Interface().(time.Time); ok {
+		return typed.Format(time.RFC3339Nano), nil
+	}
+
+	switch reflected.Kind() {
+	case reflect.String:
+		return reflected.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(reflected.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(reflected.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(reflected.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(reflected.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(reflected.Float(), 'g', -1, 64), nil
+	case reflect.Slice:
+		if reflected.Type().Elem().Kind() == reflect.Uint8 {
+			return string(reflected.Bytes()), nil
+		}
+	}
+	return nil, errors.Errorf("unsupported setting value of type %T", value)
+}
+
+// setLocalTx sets a transaction-scoped configuration parameter using set_config
+// so that the name and value are bound as parameters instead of interpolated
+func setLocalTx(ctx context.Context, postgresInstance *postgres, transaction *sqlx.Tx, name string, value any, debug bool) (err error) {
+	query, arguments := setLocalQuery(name, value)
+
+	postgresInstance.beforeQuery(ctx, debug, query, arguments)
+	defer func(started time.Time) {
+		postgresInstance.afterQuery(ctx, query, arguments, started, unknownRowsAffected, err)
+	}(time.Now())
+
+	preparedStatement, err := transaction.PrepareNamedContext(ctx, escapeNamedQuery(query))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer preparedStatement.Close()
+
+	if _, err = preparedStatement.ExecContext(ctx, arguments); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
 // insert inserts data into the database
 // and returns the inserted ID
-func insert(ctx context.Context, database *sqlx.DB, query string, arguments map[string]any) (any, error) {
+func insert(ctx context.Context, postgresInstance *postgres, query string, arguments map[string]any) (any, error) {
 	var insertedID any
-	preparedStatement, err := database.PrepareNamedContext(ctx, query)
+	preparedStatement, release, err := postgresInstance.prepareNamed(ctx, postgresInstance.database, query)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, err
 	}
-	defer preparedStatement.Close()
+	defer func() {
+		_ = release(err)
+	}()
 
 	err = preparedStatement.GetContext(ctx, &insertedID, arguments)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, noReturnedIDError(err)
+		}
 		return 0, errors.WithStack(err)
 	}
 
 	if insertedID == nil {
-		return 0, errors.WithStack(errors.New("insert operation failed: no ID was returned from the database. This may indicate that the table does not have an auto-increment primary key or the insert did not complete successfully"))
+		return 0, noReturnedIDError(nil)
 	}
 
 	return insertedID, err
 }
 
+// returning executes a query with a RETURNING clause
+// and scans the returned row into destination
+func returning(ctx context.Context, postgresInstance *postgres, query string, arguments map[string]any, destination any) error {
+	preparedStatement, release, err := postgresInstance.prepareNamed(ctx, postgresInstance.database, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = release(err)
+	}()
+
+	err = preparedStatement.GetContext(ctx, destination, arguments)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return noReturnedRowError(err)
+		}
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// noReturnedIDError is the error of an insert that returned no ID, wrapping err if it is not nil.
+func noReturnedIDError(err error) error {
+	if err == nil {
+		return errors.WithStack(fmt.Errorf("insert operation failed: no ID was returned from the database. This may indicate that the query has no RETURNING clause, the table does not have an auto-increment primary key or the insert did not complete successfully: %w", ErrNoReturningID))
+	}
+	return errors.WithStack(fmt.Errorf("insert operation failed: no row was returned from the database. Make sure the query has a RETURNING clause: %w: %w", ErrNoReturningID, err))
+}
+
+// noReturnedRowError is the error of a RETURNING query that matched no row, wrapping err.
+func noReturnedRowError(err error) error {
+	return errors.WithStack(fmt.Errorf("returning operation failed: no row was returned from the database. Make sure the query has a RETURNING clause and matches at least one row: %w", err))
+}
+
 // update updates data in the database
-func update(ctx context.Context, database *sqlx.DB, query string, arguments map[string]any) (int64, error) {
-	preparedStatement, err := database.PrepareNamedContext(ctx, query)
+func update(ctx context.Context, postgresInstance *postgres, query string, arguments map[string]any) (int64, error) {
+	preparedStatement, release, err := postgresInstance.prepareNamed(ctx, postgresInstance.database, query)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, err
 	}
-	defer preparedStatement.Close()
+	defer func() {
+		_ = release(err)
+	}()
 
 	result, err := preparedStatement.ExecContext(ctx, arguments)
 	if err != nil {
@@ -167,7 +551,7 @@ func update(ctx context.Context, database *sqlx.DB, query string, arguments map[
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return 0, nil
 		}
 		return 0, errors.WithStack(err)
@@ -176,13 +560,16 @@ func update(ctx context.Context, database *sqlx.DB, query string, arguments map[
 	return rowsAffected, nil
 }
 
-// delete deletes data from the database
-func delete(ctx context.Context, database *sqlx.DB, query string, arguments map[string]any) (int64, error) {
-	preparedStatement, err := database.PrepareNamedContext(ctx, query)
+// deleteRows deletes data from the database.
+// It is not named delete so the builtin stays usable in this package.
+func deleteRows(ctx context.Context, postgresInstance *postgres, query string, arguments map[string]any) (int64, error) {
+	preparedStatement, release, err := postgresInstance.prepareNamed(ctx, postgresInstance.database, query)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, err
 	}
-	defer preparedStatement.Close()
+	defer func() {
+		_ = release(err)
+	}()
 
 	result, err := preparedStatement.ExecContext(ctx, arguments)
 	if err != nil {
@@ -191,7 +578,7 @@ func delete(ctx context.Context, database *sqlx.DB, query string, arguments map[
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return 0, nil
 		}
 		return 0, errors.WithStack(err)
@@ -212,27 +599,189 @@ func (s *StringSlice) Scan(src any) error {
 		return nil
 	}
 
-	str = quoteEscapeRegex.ReplaceAllString(str, `$1""`)
-	str = strings.ReplaceAll(str, `\\`, `\`)
+	elements, err := parseTextArray(str)
+	if err != nil {
+		return err
+	}
+	slice := make([]string, len(elements))
+	for i, element := range elements {
+		if element == nil {
+			return fmt.Errorf("invalid string array %q: element %d is NULL, use NullableStringSlice to scan NULL elements", str, i)
+		}
+		slice[i] = *element
+	}
+	*s = slice
 
+	return nil
+}
+
+// Value encodes s as a text array literal, double-quoting every element so commas,
+// braces, quotes and backslashes round-trip through Scan.
+func (s StringSlice) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("{")
+	last := len(s) - 1
+	for i, val := range s {
+		buffer.WriteString(quoteArrayElement(val))
+		if i != last {
+			buffer.WriteString(",")
+		}
+	}
+	buffer.WriteString("}")
+
+	return buffer.String(), nil
+}
+
+func (s *IntSlice) Scan(src any) error {
+	var str string
+	switch src := src.(type) {
+	case []byte:
+		str = string(src)
+	case string:
+		str = src
+	case nil:
+		*s = nil
+		return nil
+	}
+
+	if len(str) < 2 || str[0] != '{' || str[len(str)-1] != '}' {
+		return fmt.Errorf("invalid integer array %q: expected a value like {1,2,3}", str)
+	}
 	str = str[1 : len(str)-1]
 
 	if len(str) == 0 {
-		*s = []string{}
+		*s = []int64{}
+		return nil
+	}
+
+	parts := strings.Split(str, ",")
+	slice := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		value, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer array element %q: %w", part, err)
+		}
+		slice = append(slice, value)
+	}
+	*s = slice
+
+	return nil
+}
+
+func (s IntSlice) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("{")
+	last := len(s) - 1
+	for i, val := range s {
+		buffer.WriteString(strconv.FormatInt(val, 10))
+		if i != last {
+			buffer.WriteString(",")
+		}
+	}
+	buffer.WriteString("}")
+
+	return buffer.String(), nil
+}
+
+func (s *NullableStringSlice) Scan(src any) error {
+	var str string
+	switch src := src.(type) {
+	case []byte:
+		str = string(src)
+	case string:
+		str = src
+	case nil:
+		*s = nil
+		return nil
+	}
+
+	elements, err := parseTextArray(str)
+	if err != nil {
+		return err
+	}
+	*s = elements
+
+	return nil
+}
+
+func (s NullableStringSlice) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("{")
+	last := len(s) - 1
+	for i, val := range s {
+		if val == nil {
+			buffer.WriteString("NULL")
+		} else {
+			buffer.WriteString(quoteArrayElement(*val))
+		}
+		if i != last {
+			buffer.WriteString(",")
+		}
+	}
+	buffer.WriteString("}")
+
+	return buffer.String(), nil
+}
+
+// arrayTimeLayouts are the layouts Postgres uses for timestamptz and timestamp array elements
+// with the ISO DateStyle, from the most to the least specific time zone offset.
+var arrayTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+func (s *TimeSlice) Scan(src any) error {
+	var str string
+	switch src := src.(type) {
+	case []byte:
+		str = string(src)
+	case string:
+		str = src
+	case nil:
+		*s = nil
 		return nil
 	}
 
-	csvReader := csv.NewReader(strings.NewReader(str))
-	slice, err := csvReader.Read()
+	elements, err := parseTextArray(str)
 	if err != nil {
 		return err
 	}
+
+	slice := make([]time.Time, 0, len(elements))
+	for _, element := range elements {
+		if element == nil {
+			return fmt.Errorf("invalid timestamp array %q: NULL elements are not supported", str)
+		}
+		value, err := parseArrayTime(*element)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp array element %q: %w", *element, err)
+		}
+		slice = append(slice, value)
+	}
 	*s = slice
 
 	return nil
 }
 
-func (s StringSlice) Value() (driver.Value, error) {
+func (s TimeSlice) Value() (driver.Value, error) {
 	if len(s) == 0 {
 		return nil, nil
 	}
@@ -242,7 +791,7 @@ func (s StringSlice) Value() (driver.Value, error) {
 	buffer.WriteString("{")
 	last := len(s) - 1
 	for i, val := range s {
-		buffer.WriteString(strconv.Quote(val))
+		buffer.WriteString(quoteArrayElement(val.Format("2006-01-02 15:04:05.999999Z07:00")))
 		if i != last {
 			buffer.WriteString(",")
 		}
@@ -252,38 +801,472 @@ func (s StringSlice) Value() (driver.Value, error) {
 	return buffer.String(), nil
 }
 
+// parseArrayTime parses a timestamp array element. Elements without a time zone offset,
+// from timestamp arrays, are returned in UTC.
+func parseArrayTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range arrayTimeLayouts {
+		var parsed time.Time
+		if parsed, err = time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseTextArray parses a one-dimensional Postgres text array like {a,"b c",NULL}.
+// Unquoted NULL elements are returned as nil; a quoted "NULL" is the string NULL.
+// Nested arrays such as {{a,b},{c,d}} are rejected.
+func parseTextArray(str string) ([]*string, error) {
+	if len(str) < 2 || str[0] != '{' || str[len(str)-1] != '}' {
+		return nil, fmt.Errorf("invalid string array %q: expected a value like {a,b,c}", str)
+	}
+	body := str[1 : len(str)-1]
+	if len(body) == 0 {
+		return []*string{}, nil
+	}
+
+	var elements []*string
+	for position := 0; ; {
+		var element strings.Builder
+		quoted := position < len(body) && body[position] == '"'
+		if quoted {
+			position++
+			for ; position < len(body) && body[position] != '"'; position++ {
+				if body[position] == '\\' {
+					position++
+					if position == len(body) {
+						break
+					}
+				}
+				element.WriteByte(body[position])
+			}
+			if position == len(body) {
+				return nil, fmt.Errorf("invalid string array %q: unterminated quoted element", str)
+			}
+			position++ // Closing quote
+		} else {
+			for ; position < len(body) && body[position] != ','; position++ {
+				switch body[position] {
+				case '{', '}':
+					return nil, fmt.Errorf("invalid string array %q: nested arrays are not supported", str)
+				case '"':
+					return nil, fmt.Errorf("invalid string array %q: unexpected quote in unquoted element", str)
+				}
+				element.WriteByte(body[position])
+			}
+		}
+
+		value := element.String()
+		if !quoted && strings.EqualFold(value, "NULL") {
+			elements = append(elements, nil)
+		} else {
+			elements = append(elements, &value)
+		}
+
+		if position == len(body) {
+			return elements, nil
+		}
+		if body[position] != ',' {
+			return nil, fmt.Errorf("invalid string array %q: unexpected %q after element", str, body[position])
+		}
+		position++
+	}
+}
+
+// quoteArrayElement double-quotes value for use as a Postgres array element,
+// escaping embedded backslashes and double quotes.
+func quoteArrayElement(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+// JSON holds a value stored in a json or jsonb column.
+// Valid is false when the column is NULL, in which case V is the zero value of T.
+type JSON[T any] struct {
+	V     T
+	Valid bool
+}
+
+// NewJSON returns a valid JSON holding value.
+func NewJSON[T any](value T) JSON[T] {
+	return JSON[T]{V: value, Valid: true}
+}
+
+func (j *JSON[T]) Scan(src any) error {
+	var data []byte
+	switch src := src.(type) {
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	case nil:
+		var zero T
+		j.V, j.Valid = zero, false
+		return nil
+	default:
+		return fmt.Errorf("invalid json value: cannot scan %T", src)
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid json value: %w", err)
+	}
+	j.V, j.Valid = value, true
+
+	return nil
+}
+
+// Value marshals V to JSON, or returns NULL when j is not valid.
+// The JSON is sent as text because lib/pq encodes []byte arguments as bytea.
+func (j JSON[T]) Value() (driver.Value, error) {
+	if !j.Valid {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(j.V)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json value: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// expandInClauses rewrites every IN (:key) placeholder whose argument is a slice into one
+// named parameter per element, e.g. IN (:ids) becomes IN (:__in_ids_0, :__in_ids_1, :__in_ids_2).
+// An empty slice would produce the invalid IN (), so IN (:ids) becomes = ANY ('{}'), which is
+// false, and NOT IN (:ids) becomes <> ALL ('{}'), which is true. Unlike IN (NULL), both stay
+// correct when negated or used as a value, e.g. NOT (id IN (:ids)) or id IN (:ids) AS flag.
+// Slices used anywhere else, such as = ANY(:ids) or array columns, are bound unchanged, and
+// placeholders are found like namedParameters does, so IN (:ids) inside string literals,
+// quoted identifiers, dollar-quoted strings and comments is left as is.
+// The arguments map is copied before it is modified.
+func expandInClauses(query string, arguments map[string]any) (string, map[string]any) {
+	if !hasSliceArgument(arguments) {
+		return query, arguments
+	}
+
+	var (
+		expanded map[string]any
+		builder  strings.Builder
+	)
+	position := 0
+	for _, parameter := range namedParameters(query) {
+		elements, ok := sliceElements(arguments[parameter.name])
+		if !ok {
+			continue
+		}
+		open := inClauseOpenRegex.FindStringSubmatchIndex(query[position:parameter.start])
+		closing := inClauseCloseRegex.FindStringIndex(query[parameter.end:])
+		if open == nil || closing == nil {
+			continue
+		}
+		not := ""
+		if open[2] >= 0 {
+			not = query[position+open[2] : position+open[3]]
+		}
+
+		builder.WriteString(query[position : position+open[0]])
+		position = parameter.end + closing[1]
+		if len(elements) == 0 {
+			if not != "" {
+				builder.WriteString("<> ALL ('{}')")
+			} else {
+				builder.WriteString("= ANY ('{}')")
+			}
+			continue
+		}
+
+		if expanded == nil {
+			expanded = make(map[string]any, len(arguments)+len(elements))
+			for k, v := range arguments {
+				expanded[k] = v
+			}
+		}
+		placeholders := make([]string, len(elements))
+		for i, element := range elements {
+			elementKey := generatedKeyPrefix + "in_" + parameter.name + "_" + strconv.Itoa(i)
+			placeholders[i] = ":" + elementKey
+			expanded[elementKey] = element
+		}
+		builder.WriteString(not + "IN (" + strings.Join(placeholders, ", ") + ")")
+	}
+	if position == 0 {
+		return query, arguments
+	}
+	builder.WriteString(query[position:])
+	query = builder.String()
+
+	if expanded == nil {
+		return query, arguments
+	}
+	return query, expanded
+}
+
+// hasSliceArgument returns true if any argument is a slice or array other than []byte.
+func hasSliceArgument(arguments map[string]any) bool {
+	for _, value := range arguments {
+		if _, ok := value.([]byte); ok {
+			continue
+		}
+		if kind := reflect.ValueOf(value).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			return true
+		}
+	}
+	return false
+}
+
+// sliceElements returns the elements of a slice or array value, excluding []byte.
+func sliceElements(value any) ([]any, bool) {
+	if _, ok := value.([]byte); ok {
+		return nil, false
+	}
+
+	reflectValue := reflect.ValueOf(value)
+	if reflectValue.Kind() != reflect.Slice && reflectValue.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	elements := make([]any, reflectValue.Len())
+	for i := range elements {
+		elements[i] = reflectValue.Index(i).Interface()
+	}
+	return elements, true
+}
+
+// mergeArguments returns arguments with the shared arguments it does not set added.
+// arguments is returned unchanged when shared is empty.
+func mergeArguments(shared, arguments map[string]any) map[string]any {
+	if len(shared) == 0 {
+		return arguments
+	}
+	merged := make(map[string]any, len(shared)+len(arguments))
+	for key, value := range shared {
+		merged[key] = value
+	}
+	for key, value := range arguments {
+		merged[key] = value
+	}
+	return merged
+}
+
 // Pairs converts a slice of key-value pairs to a map.
+// A single map with string keys or struct argument is used as the arguments directly,
+// see PairsFromStruct for how struct fields are named.
 func Pairs(keyValuePairs []any) (map[string]any, error) {
+	return pairsWithMapper(keyValuePairs, nil)
+}
+
+// pairsWithMapper is Pairs keying the fields of a struct argument without a db tag by fieldMapper.
+func pairsWithMapper(keyValuePairs []any, fieldMapper func(string) string) (map[string]any, error) {
+	keyValuePairs, err := singleArgumentPairs(keyValuePairs, fieldMapper)
+	if err != nil {
+		return nil, err
+	}
 	if len(keyValuePairs)%2 == 1 {
 		return nil, fmt.Errorf("invalid key-value pairs: expected even number of arguments but got %d. Key-value pairs must be provided in pairs like: key1, value1, key2, value2", len(keyValuePairs))
 	}
 	arguments := map[string]any{}
 	for i := 0; i < len(keyValuePairs); i += 2 {
 		key := fmt.Sprintf("%v", keyValuePairs[i])
+		if err = checkGeneratedKey(arguments, key); err != nil {
+			return nil, err
+		}
 		arguments[key] = keyValuePairs[i+1]
 	}
 	return arguments, nil
 }
 
+// checkGeneratedKey returns an error if key is a generated key already in arguments. It is
+// given twice when two clauses generating it, such as two searches on one column, are combined.
+func checkGeneratedKey(arguments map[string]any, key string) error {
+	if _, exists := arguments[key]; exists && strings.HasPrefix(key, generatedKeyPrefix) {
+		return errors.Errorf("invalid key-value pairs: generated key %q is given twice, the clauses generating it cannot be combined in one query", key)
+	}
+	return nil
+}
+
 // PairsHook converts a slice of key-value pairs to a map.
 // If the value is a string and starts with the hook, it will be replaced with the value from the ids map.
 func PairsHook(keyValuePairs []any, identifiers map[string]any, hook string) (map[string]any, error) {
+	return pairsHookWithMapper(keyValuePairs, identifiers, hook, nil)
+}
+
+// pairsHookWithMapper is PairsHook keying the fields of a struct argument without a db tag by fieldMapper.
+func pairsHookWithMapper(keyValuePairs []any, identifiers map[string]any, hook string, fieldMapper func(string) string) (map[string]any, error) {
+	keyValuePairs, err := singleArgumentPairs(keyValuePairs, fieldMapper)
+	if err != nil {
+		return nil, err
+	}
 	if len(keyValuePairs)%2 == 1 {
 		return nil, fmt.Errorf("invalid key-value pairs: expected even number of arguments but got %d. Key-value pairs must be provided in pairs like: key1, value1, key2, value2", len(keyValuePairs))
 	}
 	arguments := map[string]any{}
 	for i := 0; i < len(keyValuePairs); i += 2 {
 		key := fmt.Sprintf("%v", keyValuePairs[i])
+		if err = checkGeneratedKey(arguments, key); err != nil {
+			return nil, err
+		}
 		value := keyValuePairs[i+1]
 		stringValue, ok := value.(string)
-		if ok && len(stringValue) > 11 && stringValue[:11] == hook {
-			value = identifiers[stringValue[11:]]
+		if ok && hook != "" && len(stringValue) > len(hook) && strings.HasPrefix(stringValue, hook) {
+			value = identifiers[stringValue[len(hook):]]
 		}
 		arguments[key] = value
 	}
 	return arguments, nil
 }
 
+// PairsFromStruct converts a struct, or a pointer to one, to an arguments map.
+// Each exported field is keyed by its db tag, or by its lower-cased name without one, like
+// sqlx maps columns. Fields tagged db:"-" are skipped and the fields of embedded structs
+// without a tag are promoted; a nil embedded pointer contributes no fields. Zero values and
+// nil pointers are kept, so they bind as zero values and NULL.
+//
+// Struct arguments passed to a client are keyed by its WithFieldMapper instead of lower-casing,
+// so they bind the same names the client scans; PairsFromStructWithMapper does the same.
+func PairsFromStruct(v any) (map[string]any, error) {
+	return PairsFromStructWithMapper(v, nil)
+}
+
+// PairsFromStructWithMapper is PairsFromStruct keying fields without a db tag by fieldMapper,
+// e.g. SnakeCase, or by their lower-cased name if fieldMapper is nil.
+func PairsFromStructWithMapper(v any, fieldMapper func(string) string) (map[string]any, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, errors.Errorf("invalid struct arguments: nil %T", v)
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, errors.Errorf("invalid struct arguments: expected a struct or pointer to struct but got %T", v)
+	}
+
+	if fieldMapper == nil {
+		fieldMapper = strings.ToLower
+	}
+	arguments := make(map[string]any, value.NumField())
+	addStructFields(arguments, value, fieldMapper)
+	return arguments, nil
+}
+
+// addStructFields adds the fields of the struct value to arguments, promoting untagged embedded structs.
+// As in Go, a field of the outer struct wins over a promoted field with the same name.
+func addStructFields(arguments map[string]any, value reflect.Value, fieldMapper func(string) string) {
+	var embeddedStructs []reflect.Value
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, hasTag := field.Tag.Lookup("db")
+		tag, _, _ = strings.Cut(tag, ",")
+		if tag == "-" {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if field.Anonymous && !hasTag {
+			embedded := fieldValue
+			if embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				embeddedStructs = append(embeddedStructs, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() || !fieldValue.CanInterface() {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = fieldMapper(field.Name)
+		}
+		if _, exists := arguments[name]; !exists {
+			arguments[name] = fieldValue.Interface()
+		}
+	}
+
+	for _, embedded := range embeddedStructs {
+		addStructFields(arguments, embedded, fieldMapper)
+	}
+}
+
+// singleArgumentPairs expands a single map or struct argument into key-value pairs, keying
+// struct fields without a db tag by fieldMapper. Any other arguments are returned unchanged.
+func singleArgumentPairs(keyValuePairs []any, fieldMapper func(string) string) ([]any, error) {
+	if len(keyValuePairs) != 1 || keyValuePairs[0] == nil {
+		return keyValuePairs, nil
+	}
+	if _, ok := keyValuePairs[0].(driver.Valuer); ok {
+		return keyValuePairs, nil
+	}
+
+	var arguments map[string]any
+	value := reflect.ValueOf(keyValuePairs[0])
+	switch {
+	case value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String:
+		arguments = make(map[string]any, value.Len())
+		for iterator := value.MapRange(); iterator.Next(); {
+			arguments[iterator.Key().String()] = iterator.Value().Interface()
+		}
+	case value.Kind() == reflect.Struct, value.Kind() == reflect.Pointer && value.Type().Elem().Kind() == reflect.Struct:
+		if _, ok := keyValuePairs[0].(time.Time); ok {
+			return keyValuePairs, nil
+		}
+		var err error
+		if arguments, err = PairsFromStructWithMapper(keyValuePairs[0], fieldMapper); err != nil {
+			return nil, err
+		}
+	default:
+		return keyValuePairs, nil
+	}
+
+	pairs := make([]any, 0, len(arguments)*2)
+	for key, argument := range arguments {
+		pairs = append(pairs, key, argument)
+	}
+	return pairs, nil
+}
+
+// quoteIdentifier quotes a possibly schema- or alias-qualified identifier,
+// e.g. p.search becomes "p"."search". Embedded double quotes are escaped.
+func quoteIdentifier(identifier string) string {
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		parts[i] = quoteName(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteName quotes a single name, such as a schema, as an identifier. Embedded double quotes are escaped.
+func quoteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// SnakeCase converts a Go field name to snake case, keeping acronyms together,
+// e.g. CreatedAt becomes created_at and UserID becomes user_id. Use it with WithFieldMapper.
+func SnakeCase(name string) string {
+	runes := []rune(name)
+	var builder strings.Builder
+	for i, character := range runes {
+		if unicode.IsUpper(character) {
+			// Start a new word after a lower-case letter or digit, or before the last letter of an acronym
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				builder.WriteByte('_')
+			}
+			character = unicode.ToLower(character)
+		}
+		builder.WriteRune(character)
+	}
+	return builder.String()
+}
+
 // Filter filters the slice of strings based on the map.
 func Filter(slice []string, filterMap map[string]string) (result []string) {
 	for _, value := range slice {
@@ -0,0 +1,409 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+func TestHasReturning(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"UPDATE t SET n = 1 WHERE id = :id RETURNING id", true},
+		{"delete from t where id = :id returning *", true},
+		{"INSERT INTO t (a) VALUES (:a)\nRETURNING\tid", true},
+		{"UPDATE t SET note = 'returning soon' WHERE id = :id", false},
+		{"UPDATE t SET note = E'it\\'s returning' WHERE id = :id", false},
+		{"UPDATE t SET note = 'it''s returning' WHERE id = :id", false},
+		{`UPDATE t SET "returning" = 1 WHERE id = :id`, false},
+		{"UPDATE t SET n = 1 WHERE id = :id -- returning id", false},
+		{"UPDATE t SET n = 1 /* returning /* nested */ id */ WHERE id = :id", false},
+		{"UPDATE t SET note = $$returning$$ WHERE id = :id", false},
+		{"WITH moved AS (DELETE FROM a RETURNING id) SELECT count(*) FROM moved", false},
+		{"UPDATE t SET returning_at = now() WHERE id = :id", false},
+	}
+	for _, test := range tests {
+		if got := hasReturning(test.query); got != test.want {
+			t.Errorf("hasReturning(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestQueryType(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"  -- comment\n INSERT INTO t VALUES (1)", qInsert},
+		{"/* update */ SELECT 1", qSelect},
+		{"WITH x AS (SELECT 1) UPDATE t SET n = 1", qUpdate},
+		{"WITH x AS (SELECT ')' AS p) DELETE FROM t", qDelete},
+		{"MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN DO NOTHING", qMerge},
+	}
+	for _, test := range tests {
+		if got := queryType(test.query); got != test.want {
+			t.Errorf("queryType(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestPipelineUpdateReturningFeedsLaterStep(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []driver.NamedValue
+	)
+	db := newFakeDriver(func(_ context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		if strings.Contains(query, "RETURNING") {
+			return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(42)}}}, nil
+		}
+		mu.Lock()
+		received = args
+		mu.Unlock()
+		return fakeResult{rowsAffected: 1}, nil
+	}).client(t)
+
+	update := "UPDATE accounts SET balance = balance - :amount WHERE id = :id RETURNING id"
+	result, err := db.Update(update, "amount", 10, "id", 1).
+		Insert("INSERT INTO ledger (account_id, note) VALUES (:account_id, 'returning funds')", "account_id", db.FromResult(update)).
+		NoReturn().
+		ExecInTx(context.Background())
+	if err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+	if got := result.TxResult(update); got != int64(42) {
+		t.Errorf("TxResult = %v, want 42", got)
+	}
+	if len(received) != 1 || received[0].Value != int64(42) {
+		t.Errorf("ledger insert got arguments %+v, want the returned id 42", received)
+	}
+}
+
+type tenantID string
+
+func TestSettingValue(t *testing.T) {
+	var nilPointer *int
+	number := 42
+	tests := []struct {
+		value any
+		want  any
+	}{
+		{nil, nil},
+		{nilPointer, nil},
+		{&number, "42"},
+		{"acme", "acme"},
+		{tenantID("acme"), "acme"},
+		{[]byte{'a', 'b'}, "ab"},
+		{true, "true"},
+		{int64(-7), "-7"},
+		{uint8(7), "7"},
+		{1e6, "1e+06"},
+		{float32(0.5), "0.5"},
+		{time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "2026-01-02T03:04:05Z"},
+		{sql.NullString{}, nil},
+		{sql.NullString{String: "x", Valid: true}, "x"},
+	}
+	for _, test := range tests {
+		got, err := settingValue(test.value)
+		if err != nil || got != test.want {
+			t.Errorf("settingValue(%#v) = %#v, %v, want %#v", test.value, got, err, test.want)
+		}
+	}
+
+	for _, value := range []any{[]int{1, 2}, map[string]string{}, struct{}{}} {
+		if _, err := settingValue(value); err == nil {
+			t.Errorf("settingValue(%#v) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestSetLocalRejectsUnsupportedValue(t *testing.T) {
+	db := newFakeDriver(nil).client(t)
+	_, err := db.Insert("INSERT INTO t (a) VALUES (:a) RETURNING id", "a", 1).
+		SetLocal("app.ids", []int{1, 2}).
+		ExecInTx(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `invalid value for setting "app.ids"`) {
+		t.Errorf("ExecInTx() = %v, want an invalid setting error", err)
+	}
+}
+
+func TestExpandInClauses(t *testing.T) {
+	tests := []struct {
+		query     string
+		arguments map[string]any
+		want      string
+		expanded  map[string]any
+	}{
+		{
+			"SELECT * FROM t WHERE id IN (:ids) AND kind in ( :kinds )",
+			map[string]any{"ids": []int{1, 2}, "kinds": []string{"a"}},
+			"SELECT * FROM t WHERE id IN (:__in_ids_0, :__in_ids_1) AND kind IN (:__in_kinds_0)",
+			map[string]any{"__in_ids_0": 1, "__in_ids_1": 2, "__in_kinds_0": "a"},
+		},
+		{
+			// ids_0 is a caller key and must not be overwritten by the elements of ids
+			"SELECT * FROM t WHERE id NOT IN (:ids) AND id <> :ids_0",
+			map[string]any{"ids": []int{1}, "ids_0": 9},
+			"SELECT * FROM t WHERE id NOT IN (:__in_ids_0) AND id <> :ids_0",
+			map[string]any{"ids_0": 9, "__in_ids_0": 1},
+		},
+		{
+			"SELECT * FROM t WHERE id IN (:ids) OR id NOT IN (:ids)",
+			map[string]any{"ids": []int{}},
+			"SELECT * FROM t WHERE id = ANY ('{}') OR id <> ALL ('{}')",
+			nil,
+		},
+		{
+			"SELECT * FROM t WHERE id = ANY(:ids) AND tag IN (:tag) AND x IN (:missing)",
+			map[string]any{"ids": []int{1}, "tag": "a"},
+			"SELECT * FROM t WHERE id = ANY(:ids) AND tag IN (:tag) AND x IN (:missing)",
+			nil,
+		},
+		{
+			// An empty list stays false when negated or selected, unlike IN (NULL)
+			"SELECT id IN (:ids) AS flag FROM t WHERE NOT (id IN (:ids)) AND CASE WHEN id NOT IN (:ids) THEN true END",
+			map[string]any{"ids": []int{}},
+			"SELECT id = ANY ('{}') AS flag FROM t WHERE NOT (id = ANY ('{}')) AND CASE WHEN id <> ALL ('{}') THEN true END",
+			nil,
+		},
+		{
+			// Literals, comments and dollar-quoted bodies keep their text
+			"SELECT 'x IN (:ids)', $$y IN (:ids)$$ FROM t /* z IN (:ids) */ WHERE id IN(:ids) -- w IN (:ids)",
+			map[string]any{"ids": []int{1}},
+			"SELECT 'x IN (:ids)', $$y IN (:ids)$$ FROM t /* z IN (:ids) */ WHERE id IN (:__in_ids_0) -- w IN (:ids)",
+			map[string]any{"__in_ids_0": 1},
+		},
+	}
+	for _, test := range tests {
+		query, arguments := expandInClauses(test.query, test.arguments)
+		if query != test.want {
+			t.Errorf("expandInClauses(%q) = %q, want %q", test.query, query, test.want)
+		}
+		for key, want := range test.expanded {
+			if arguments[key] != want {
+				t.Errorf("expandInClauses(%q) bound %s to %v, want %v", test.query, key, arguments[key], want)
+			}
+		}
+		if len(test.expanded) > 0 && len(test.arguments) == len(arguments) {
+			t.Errorf("expandInClauses(%q) modified the arguments map in place", test.query)
+		}
+	}
+}
+
+func BenchmarkExpandInClauses(b *testing.B) {
+	query := "SELECT * FROM t WHERE id IN (:ids) AND kind IN (:kinds) AND owner = :owner"
+	arguments := map[string]any{"ids": []int{1, 2, 3, 4, 5}, "kinds": []string{"a", "b"}, "owner": 7}
+	for b.Loop() {
+		expandInClauses(query, arguments)
+	}
+}
+
+func BenchmarkExpandInClausesWithoutSlices(b *testing.B) {
+	query := "SELECT * FROM t WHERE id = :id AND owner = :owner"
+	arguments := map[string]any{"id": 1, "owner": 7}
+	for b.Loop() {
+		expandInClauses(query, arguments)
+	}
+}
+
+type auditFields struct {
+	CreatedBy string
+}
+
+type account struct {
+	ID        int64 `db:"id"`
+	OwnerName string
+	CreatedAt time.Time
+	Secret    string `db:"-"`
+	auditFields
+}
+
+func TestPairsFromStructMatchesScanMapping(t *testing.T) {
+	value := account{ID: 1, OwnerName: "ann", CreatedAt: time.Unix(0, 0), auditFields: auditFields{CreatedBy: "bob"}}
+	for _, fieldMapper := range []func(string) string{nil, SnakeCase} {
+		arguments, err := PairsFromStructWithMapper(&value, fieldMapper)
+		if err != nil {
+			t.Fatalf("PairsFromStructWithMapper: %v", err)
+		}
+
+		scanMapper := strings.ToLower
+		if fieldMapper != nil {
+			scanMapper = fieldMapper
+		}
+		fields := reflectx.NewMapperFunc("db", scanMapper).FieldMap(reflect.ValueOf(value))
+		if len(arguments) != 4 {
+			t.Errorf("arguments = %v, want 4 fields", arguments)
+		}
+		for key, argument := range arguments {
+			field, exists := fields[key]
+			if !exists {
+				t.Errorf("argument %s is not a field sqlx scans", key)
+				continue
+			}
+			if !reflect.DeepEqual(field.Interface(), argument) {
+				t.Errorf("argument %s = %v, sqlx scans %v", key, argument, field.Interface())
+			}
+		}
+	}
+}
+
+func TestStructArgumentsUseFieldMapper(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []driver.NamedValue
+	)
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	db := newFakeDriver(func(_ context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasPrefix(query, "SELECT") {
+			return fakeResult{
+				columns: []string{"id", "owner_name", "created_at", "created_by"},
+				rows:    [][]driver.Value{{int64(1), "ann", created, "bob"}},
+			}, nil
+		}
+		received = args
+		return returningOne(context.Background(), query, args)
+	}).client(t, WithFieldMapper(SnakeCase))
+
+	inserted := account{OwnerName: "ann", CreatedAt: created, auditFields: auditFields{CreatedBy: "bob"}}
+	_, err := db.Insert("INSERT INTO accounts (owner_name, created_at, created_by) VALUES (:owner_name, :created_at, :created_by) RETURNING id", inserted).
+		ExecInsert(context.Background())
+	if err != nil {
+		t.Fatalf("ExecInsert: %v", err)
+	}
+	if len(received) != 3 || received[0].Value != "ann" || received[2].Value != "bob" {
+		t.Errorf("insert bound %v", received)
+	}
+
+	var scanned account
+	if _, err = db.Select("SELECT * FROM accounts WHERE id = :id", &scanned, "id", 1).One(context.Background()); err != nil {
+		t.Fatalf("One: %v", err)
+	}
+	inserted.ID = 1
+	if !reflect.DeepEqual(scanned, inserted) {
+		t.Errorf("scanned %+v, want %+v", scanned, inserted)
+	}
+}
+
+func TestDebugQueryReplacesWholeNames(t *testing.T) {
+	output := captureStdout(t, func() {
+		debugQuery("SELECT * FROM t WHERE id = :id AND id_2 = :id_2 AND identifier = :identifier AND x = :id",
+			map[string]any{"id": 1, "id_2": 2, "identifier": "abc"}, nil)
+	})
+	if want := "[DEBUG SQL] SELECT * FROM t WHERE id = 1 AND id_2 = 2 AND identifier = 'abc' AND x = 1\n"; output != want {
+		t.Errorf("debugQuery printed %q, want %q", output, want)
+	}
+}
+
+func TestDebugValue(t *testing.T) {
+	var nilPointer *string
+	text := "it's"
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"plain", "'plain'"},
+		{"it's 'quoted'", "'it''s ''quoted'''"},
+		{&text, "'it''s'"},
+		{42, "42"},
+		{int64(-7), "-7"},
+		{3.5, "3.5"},
+		{true, "true"},
+		{false, "false"},
+		{nil, "NULL"},
+		{nilPointer, "NULL"},
+		{[]byte("raw"), "'raw'"},
+		{time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "'2026-01-02T03:04:05Z'"},
+	}
+	for _, test := range tests {
+		if got := debugValue(test.value); got != test.want {
+			t.Errorf("debugValue(%#v) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestDebugOutputShowsValuerArguments(t *testing.T) {
+	db := newFakeDriver(selectOne).client(t)
+
+	var id int64
+	output := captureStdout(t, func() {
+		_, err := db.Select("SELECT id FROM users WHERE tags && :tags AND scores = :scores", &id, "tags", StringSlice{"a", "b c"}, "scores", IntSlice{1, 2}).
+			Debug().One(context.Background())
+		if err != nil {
+			t.Errorf("One: %v", err)
+		}
+	})
+	if want := `tags && '{"a","b c"}' AND scores = '{1,2}'`; !strings.Contains(output, want) {
+		t.Errorf("debug output = %q, want it to contain %q", output, want)
+	}
+}
+
+func TestPairsWithSingleArgument(t *testing.T) {
+	type profile struct {
+		Name    string  `db:"name"`
+		Age     int     `db:"age"`
+		Nick    *string `db:"nick"`
+		private string
+	}
+	type member struct {
+		profile
+		Team string `db:"team"`
+	}
+
+	tests := []struct {
+		name     string
+		argument any
+		want     map[string]any
+	}{
+		{"map", map[string]any{"id": 1, "name": "ann"}, map[string]any{"id": 1, "name": "ann"}},
+		{"typed map", map[string]string{"name": "ann"}, map[string]any{"name": "ann"}},
+		{"tagged struct", profile{Name: "ann", Age: 30, private: "hidden"}, map[string]any{"name": "ann", "age": 30, "nick": (*string)(nil)}},
+		{"zero struct", &profile{}, map[string]any{"name": "", "age": 0, "nick": (*string)(nil)}},
+		{"embedded struct", member{profile: profile{Name: "ann"}, Team: "core"}, map[string]any{"name": "ann", "age": 0, "nick": (*string)(nil), "team": "core"}},
+	}
+	for _, test := range tests {
+		got, err := Pairs([]any{test.argument})
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Pairs(%s) = %#v, %v, want %#v", test.name, got, err, test.want)
+		}
+	}
+
+	if _, err := Pairs([]any{(*profile)(nil)}); err == nil || !strings.Contains(err.Error(), "nil") {
+		t.Errorf("Pairs(nil struct pointer) = %v, want an error", err)
+	}
+	if _, err := Pairs([]any{nil}); err == nil {
+		t.Error("Pairs(nil) succeeded, want an odd pairs error")
+	}
+	// A single time or valuer is a value, not arguments
+	if _, err := Pairs([]any{time.Now()}); err == nil {
+		t.Error("Pairs(time) succeeded, want an odd pairs error")
+	}
+}
+
+func TestEmptyInListSelectsNothing(t *testing.T) {
+	recorder := &queryRecorder{}
+	db := newFakeDriver(func(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		_, _ = recorder.handle(ctx, query, args)
+		return fakeResult{columns: []string{"id"}}, nil
+	}).client(t, WithMaxOpenConns(1))
+
+	var ids []int64
+	if _, err := db.Select("SELECT id FROM users WHERE id IN (:ids) AND active = :active", &ids, "ids", []int{}, "active", true).Many(context.Background()); err != nil || len(ids) != 0 {
+		t.Fatalf("Many = %v with %v, want no rows and no error", err, ids)
+	}
+	if want := "SELECT id FROM users WHERE id = ANY ('{}') AND active = $1"; recorder.queries[0] != want {
+		t.Errorf("query = %s, want %s", recorder.queries[0], want)
+	}
+	if !reflect.DeepEqual(recorder.args[0], []any{true}) {
+		t.Errorf("arguments = %v, want only active", recorder.args[0])
+	}
+}
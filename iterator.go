@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// Iterator is a cursor over the rows of a select query.
+// Rows are read one at a time, so memory usage stays bounded for large result sets.
+// Iterator is not safe for concurrent use.
+type Iterator struct {
+	postgres         *postgres
+	releaseStatement func(err error) error // nil for positional queries without a statement timeout
+	cancel           context.CancelFunc    // Ends the WithDefaultTimeout context of the query
+	rows             *sqlx.Rows
+	err              error
+	closed           bool
+}
+
+// Next prepares the next row for Scan. It returns false when there are no more rows
+// or an error occurred, and closes the iterator in that case.
+func (iterator *Iterator) Next() bool {
+	if iterator.closed {
+		return false
+	}
+	if iterator.rows.Next() {
+		return true
+	}
+	iterator.err = iterator.rows.Err()
+	_ = iterator.Close()
+	return false
+}
+
+// Scan copies the current row into destination.
+// destination is a pointer to a struct mapped by db tags, or to a single value.
+func (iterator *Iterator) Scan(destination any) error {
+	if iterator.closed {
+		return iterator.postgres.wrapError(errors.New("iterator is closed"))
+	}
+
+	var err error
+	if isStructDestination(destination) {
+		err = iterator.rows.StructScan(destination)
+	} else {
+		err = iterator.rows.Scan(destination)
+	}
+	return iterator.postgres.wrapError(errors.WithStack(err))
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+// The iteration stops early when the context passed to Rows is cancelled.
+func (iterator *Iterator) Err() error {
+	return iterator.postgres.wrapError(errors.WithStack(iterator.err))
+}
+
+// Close closes the rows and the prepared statement. It is safe to call more than once.
+func (iterator *Iterator) Close() error {
+	if iterator.closed {
+		return nil
+	}
+	iterator.closed = true
+
+	rowsErr := iterator.rows.Close()
+	if iterator.releaseStatement != nil {
+		if statementErr := iterator.releaseStatement(iterator.err); rowsErr == nil {
+			rowsErr = statementErr
+		}
+	}
+	iterator.cancel()
+	return iterator.postgres.wrapError(errors.WithStack(rowsErr))
+}
+
+// isStructDestination returns true if destination should be scanned column by column into struct fields.
+func isStructDestination(destination any) bool {
+	typ := reflect.TypeOf(destination)
+	if typ == nil || typ.Kind() != reflect.Pointer {
+		return false
+	}
+	if typ.Implements(scannerType) {
+		return false
+	}
+
+	typ = typ.Elem()
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
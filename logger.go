@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redactedValue = "***"
+
+	// unknownRowsAffected is reported to observers when the number of rows is not known.
+	unknownRowsAffected = -1
+)
+
+// Logger receives every query executed by the client.
+// Configure it with WithLogger to route query logs to slog, zap or any other logger.
+type Logger interface {
+	// LogQuery is called after a query completes with its arguments, duration and error.
+	LogQuery(ctx context.Context, query string, args map[string]any, duration time.Duration, err error)
+}
+
+// Observer receives metrics for every query executed by the client.
+// Configure it with WithObserver to feed counters and histograms, e.g. in Prometheus or OpenTelemetry.
+type Observer interface {
+	// ObserveQuery is called after a query completes. queryType is insert, update, delete, merge
+	// or select. rowsAffected is the number of rows written or returned, or -1 if not known.
+	ObserveQuery(queryType string, duration time.Duration, rowsAffected int64, err error)
+}
+
+// ContextFieldsLogger is a Logger that also receives the fields extracted from the query context
+// by the function configured with WithContextFields. Loggers that only implement Logger
+// are called through LogQuery without the fields.
+type ContextFieldsLogger interface {
+	Logger
+
+	// LogQueryWithFields is called instead of LogQuery when context fields are configured.
+	LogQueryWithFields(ctx context.Context, query string, args map[string]any, fields map[string]any, duration time.Duration, err error)
+}
+
+// WarningLogger is a Logger that also receives warnings about the client configuration,
+// such as the use of a deprecated option. Loggers that only implement Logger are not warned.
+type WarningLogger interface {
+	Logger
+
+	// LogWarning is called once when the client is created for each configuration warning.
+	LogWarning(ctx context.Context, message string)
+}
+
+// logWarnings reports configuration warnings to the configured logger, if it is a WarningLogger.
+func (postgresInstance *postgres) logWarnings(ctx context.Context, warnings []string) {
+	warningLogger, ok := postgresInstance.logger.(WarningLogger)
+	if !ok {
+		return
+	}
+	for _, warning := range warnings {
+		warningLogger.LogWarning(ctx, warning)
+	}
+}
+
+// beforeQuery prints the debug output of a query.
+// When a logger is configured it receives the query instead, after execution.
+func (postgresInstance *postgres) beforeQuery(ctx context.Context, debug bool, query string, arguments map[string]any) {
+	if debug && postgresInstance.logger == nil {
+		debugQuery(query, postgresInstance.redact(arguments), postgresInstance.contextFields(ctx))
+	}
+}
+
+// beforePositionalQuery is beforeQuery for a query with $N placeholders. Its arguments are
+// keyed by placeholder, like the arguments passed to the logger, so both redact the same keys.
+func (postgresInstance *postgres) beforePositionalQuery(ctx context.Context, debug bool, query string, arguments []any) {
+	if debug && postgresInstance.logger == nil {
+		debugPositionalQuery(query, postgresInstance.redact(positionalArguments(arguments)), postgresInstance.contextFields(ctx))
+	}
+}
+
+// afterQuery reports an executed query to the configured logger and observer and returns its duration.
+// With WithSampleRate, a successful query is only reported if it is sampled.
+func (postgresInstance *postgres) afterQuery(ctx context.Context, query string, arguments map[string]any, started time.Time, rowsAffected int64, err error) time.Duration {
+	duration := time.Since(started)
+	if err == nil && !postgresInstance.sampled() {
+		return duration
+	}
+	if fieldsLogger, ok := postgresInstance.logger.(ContextFieldsLogger); ok && postgresInstance.contextFieldsFunc != nil {
+		fieldsLogger.LogQueryWithFields(ctx, query, postgresInstance.redact(arguments), postgresInstance.contextFields(ctx), duration, err)
+	} else if postgresInstance.logger != nil {
+		postgresInstance.logger.LogQuery(ctx, query, postgresInstance.redact(arguments), duration, err)
+	}
+	if postgresInstance.observer != nil {
+		postgresInstance.observer.ObserveQuery(queryType(query), duration, rowsAffected, err)
+	}
+	return duration
+}
+
+// sampled returns true if a successful query is reported, picking queries at random at the
+// WithSampleRate rate.
+func (postgresInstance *postgres) sampled() bool {
+	return !postgresInstance.sampling || rand.Float64() < postgresInstance.sampleRate
+}
+
+// selectedRows returns the number of rows a select scanned into destination, or 0 if none was found.
+func selectedRows(found bool, destination any) int64 {
+	if !found {
+		return 0
+	}
+	return returnedRows(destination)
+}
+
+// returnedRows returns the number of rows a select scanned into destination:
+// the length of a slice destination, or 1 for a single row.
+func returnedRows(destination any) int64 {
+	value := reflect.ValueOf(destination)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Slice {
+		return int64(value.Len())
+	}
+	return 1
+}
+
+// contextFields returns the fields extracted from ctx for debug and log output, if configured.
+func (postgresInstance *postgres) contextFields(ctx context.Context) map[string]any {
+	if postgresInstance.contextFieldsFunc == nil {
+		return nil
+	}
+	return postgresInstance.contextFieldsFunc(ctx)
+}
+
+// formatFields formats fields as space-separated key=value pairs sorted by key,
+// followed by a space, or returns an empty string when there are none.
+func formatFields(fields map[string]any) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&builder, "%s=%v ", key, fields[key])
+	}
+	return builder.String()
+}
+
+// redact returns a copy of arguments with the values of redacted keys masked.
+func (postgresInstance *postgres) redact(arguments map[string]any) map[string]any {
+	if len(postgresInstance.redactedKeys) == 0 || len(arguments) == 0 {
+		return arguments
+	}
+
+	redacted := make(map[string]any, len(arguments))
+	for key, value := range arguments {
+		if _, ok := postgresInstance.redactedKeys[key]; ok {
+			value = redactedValue
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// positionalArguments converts positional arguments to a map keyed by $N for logging.
+func positionalArguments(arguments []any) map[string]any {
+	mapped := make(map[string]any, len(arguments))
+	for i, argument := range arguments {
+		mapped["$"+strconv.Itoa(i+1)] = argument
+	}
+	return mapped
+}
@@ -0,0 +1,11 @@
+//go:build newrelic
+
+package postgres
+
+import (
+	_ "github.com/newrelic/go-agent/v3/integrations/nrpq"
+)
+
+// newRelicSupported reports whether this build registers the New Relic instrumented driver.
+// The driver is only compiled in with the "newrelic" build tag.
+const newRelicSupported = true
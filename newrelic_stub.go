@@ -0,0 +1,7 @@
+//go:build !newrelic
+
+package postgres
+
+// newRelicSupported reports whether this build registers the New Relic instrumented driver.
+// The driver is only compiled in with the "newrelic" build tag.
+const newRelicSupported = false
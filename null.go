@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"database/sql"
+	"reflect"
+	"time"
+)
+
+// Null returns the value value points to, or nil if value is a nil pointer, so optional
+// fields can be passed to key-value pairs directly and are written as SQL NULL when unset.
+// Values that are not pointers are returned unchanged.
+//
+// Only nil becomes NULL: a pointer to an empty string writes an empty string, not NULL.
+//
+// Example:
+//
+//	db.Update("UPDATE users SET note = :note WHERE id = :id", "note", postgres.Null(request.Note), "id", id)
+func Null(value any) any {
+	reflectValue := reflect.ValueOf(value)
+	if reflectValue.Kind() != reflect.Pointer {
+		return value
+	}
+	if reflectValue.IsNil() {
+		return nil
+	}
+	return reflectValue.Elem().Interface()
+}
+
+// NullString returns a sql.NullString that is NULL when value is nil.
+// A pointer to an empty string is a valid empty string, not NULL.
+func NullString(value *string) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *value, Valid: true}
+}
+
+// NullInt64 returns a sql.NullInt64 that is NULL when value is nil.
+func NullInt64(value *int64) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *value, Valid: true}
+}
+
+// NullFloat64 returns a sql.NullFloat64 that is NULL when value is nil.
+func NullFloat64(value *float64) sql.NullFloat64 {
+	if value == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *value, Valid: true}
+}
+
+// NullBool returns a sql.NullBool that is NULL when value is nil.
+func NullBool(value *bool) sql.NullBool {
+	if value == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *value, Valid: true}
+}
+
+// NullTime returns a sql.NullTime that is NULL when value is nil.
+func NullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *value, Valid: true}
+}
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// observation is a query reported to recordingObserver.
+type observation struct {
+	queryType    string
+	rowsAffected int64
+	err          error
+}
+
+// recordingObserver records every observed query.
+type recordingObserver struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (o *recordingObserver) ObserveQuery(queryType string, _ time.Duration, rowsAffected int64, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observations = append(o.observations, observation{queryType, rowsAffected, err})
+}
+
+func (o *recordingObserver) last() observation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.observations[len(o.observations)-1]
+}
+
+func TestPipelineEmptySelectReportsNoRows(t *testing.T) {
+	db := newFakeDriver(func(_ context.Context, query string, _ []driver.NamedValue) (fakeResult, error) {
+		if strings.HasPrefix(query, "SELECT") {
+			return fakeResult{columns: []string{"id"}}, nil
+		}
+		return fakeResult{rowsAffected: 1}, nil
+	}).client(t)
+	observer := &recordingObserver{}
+	db.observer = observer
+
+	var id int64
+	result, err := db.Update("UPDATE users SET seen = true WHERE id = :id", "id", 1).
+		Select("SELECT id FROM users WHERE email = :email", &id, "email", "nobody@example.com").
+		ExecInTx(context.Background())
+	if err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+	if got := result.RowsAffected("SELECT id FROM users WHERE email = :email"); got != 0 {
+		t.Errorf("RowsAffected = %d, want 0", got)
+	}
+	if got := observer.last(); got.queryType != qSelect || got.rowsAffected != 0 {
+		t.Errorf("observed %+v, want a select with 0 rows", got)
+	}
+}
+
+func TestObserverCounts(t *testing.T) {
+	failure := errors.New("boom")
+	db := newFakeDriver(func(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		if strings.Contains(query, "broken") {
+			return fakeResult{}, failure
+		}
+		if strings.HasPrefix(query, "SELECT") {
+			return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}}, nil
+		}
+		return returningOne(ctx, query, args)
+	}).client(t)
+	observer := &recordingObserver{}
+	db.observer = observer
+	ctx := context.Background()
+
+	if _, err := db.Insert("INSERT INTO users (name) VALUES (:name) RETURNING id", "name", "a").ExecInsert(ctx); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	var ids []int64
+	if _, err := db.Select("SELECT id FROM users", &ids).Many(ctx); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	var count int64
+	if err := db.Scalar(ctx, "SELECT broken FROM users", &count); !errors.Is(err, failure) {
+		t.Fatalf("scalar error = %v, want %v", err, failure)
+	}
+
+	want := []observation{
+		{qInsert, 1, nil},
+		{qSelect, 2, nil},
+		{qSelect, 0, failure},
+	}
+	if len(observer.observations) != len(want) {
+		t.Fatalf("observed %d queries, want %d: %+v", len(observer.observations), len(want), observer.observations)
+	}
+	for i, got := range observer.observations {
+		if got.queryType != want[i].queryType || got.rowsAffected != want[i].rowsAffected || !errors.Is(got.err, want[i].err) {
+			t.Errorf("observation %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestSampleRateReportsShareOfSuccessesAndEveryError(t *testing.T) {
+	failure := errors.New("boom")
+	db := newFakeDriver(func(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		if strings.Contains(query, "fail") {
+			return fakeResult{}, failure
+		}
+		return selectOne(ctx, query, args)
+	})
+	observer := &recordingObserver{}
+	client := db.client(t, WithObserver(observer), WithSampleRate(0.25))
+	ctx := context.Background()
+
+	const successes, failures = 4000, 200
+	for range successes {
+		var id int64
+		if _, err := client.Select("SELECT id FROM t", &id).One(ctx); err != nil {
+			t.Fatalf("One: %v", err)
+		}
+	}
+	for range failures {
+		var id int64
+		if _, err := client.Select("SELECT id FROM fail", &id).One(ctx); err == nil {
+			t.Fatal("failing query succeeded")
+		}
+	}
+
+	var reported, reportedErrors int
+	for _, observed := range observer.observations {
+		if observed.err != nil {
+			reportedErrors++
+		} else {
+			reported++
+		}
+	}
+	// 1000 expected, the binomial standard deviation is about 27
+	if reported < 850 || reported > 1150 {
+		t.Errorf("reported %d of %d successful queries at a 0.25 rate", reported, successes)
+	}
+	if reportedErrors != failures {
+		t.Errorf("reported %d of %d failed queries, want all", reportedErrors, failures)
+	}
+}
+
+func TestSampleRateBounds(t *testing.T) {
+	for _, test := range []struct {
+		rate float64
+		want int
+	}{{0, 0}, {1, 50}} {
+		observer := &recordingObserver{}
+		client := newFakeDriver(selectOne).client(t, WithObserver(observer), WithSampleRate(test.rate))
+		for range 50 {
+			var id int64
+			if _, err := client.Select("SELECT id FROM t", &id).One(context.Background()); err != nil {
+				t.Fatalf("One: %v", err)
+			}
+		}
+		if len(observer.observations) != test.want {
+			t.Errorf("rate %v reported %d of 50 queries, want %d", test.rate, len(observer.observations), test.want)
+		}
+	}
+
+	if _, err := NewWithDB(newFakeDriver(nil).db(t), WithSampleRate(1.5)); err == nil {
+		t.Error("NewWithDB() with a sample rate of 1.5 succeeded")
+	}
+}
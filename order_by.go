@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// OrderBy builds an ORDER BY clause from a user-supplied sort, such as a sort query parameter.
+// requested is a comma-separated list of sort keys, each optionally prefixed with - for
+// descending or + for ascending order, or followed by asc or desc, e.g. "-created_at,name".
+// allowed maps every sort key an API accepts to the column it sorts by; the column is quoted
+// and never taken from requested, so only allowed columns can reach the query.
+//
+// It returns " ORDER BY <columns>", ready to append to a query, or an empty string if
+// requested is empty. An unknown key or direction returns an error listing the allowed keys.
+//
+// Example:
+//
+//	orderBy, err := postgres.OrderBy(map[string]string{"name": "u.name", "created": "u.created_at"}, r.URL.Query().Get("sort"))
+//	db.Select("SELECT * FROM users u"+orderBy, &users).Many(ctx)
+func OrderBy(allowed map[string]string, requested string) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return "", nil
+	}
+
+	var columns []string
+	for _, sortKey := range strings.Split(requested, ",") {
+		fields := strings.Fields(sortKey)
+		if len(fields) == 0 || len(fields) > 2 {
+			return "", errors.Errorf("invalid sort %q: expected a key with an optional direction", strings.TrimSpace(sortKey))
+		}
+
+		key, direction := fields[0], "ASC"
+		switch {
+		case strings.HasPrefix(key, "-"):
+			key, direction = key[1:], "DESC"
+		case strings.HasPrefix(key, "+"):
+			key = key[1:]
+		}
+		if len(fields) == 2 {
+			if key != fields[0] {
+				return "", errors.Errorf("invalid sort %q: use either a +/- prefix or a direction", strings.TrimSpace(sortKey))
+			}
+			switch strings.ToUpper(fields[1]) {
+			case "ASC":
+			case "DESC":
+				direction = "DESC"
+			default:
+				return "", errors.Errorf("invalid sort direction %q: expected asc or desc", fields[1])
+			}
+		}
+
+		column, exists := allowed[key]
+		if !exists {
+			return "", errors.Errorf("invalid sort key %q: expected one of %s", key, strings.Join(sortKeys(allowed), ", "))
+		}
+		columns = append(columns, quoteIdentifier(column)+" "+direction)
+	}
+
+	return " ORDER BY " + strings.Join(columns, ", "), nil
+}
+
+// sortKeys returns the keys of allowed in sorted order.
+func sortKeys(allowed map[string]string) []string {
+	keys := make([]string, 0, len(allowed))
+	for key := range allowed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrderBy(t *testing.T) {
+	allowed := map[string]string{"name": "u.name", "created": "u.created_at", "id": "id"}
+	tests := map[string]string{
+		"":                    "",
+		"name":                ` ORDER BY "u"."name" ASC`,
+		"-created":            ` ORDER BY "u"."created_at" DESC`,
+		"+name, -id":          ` ORDER BY "u"."name" ASC, "id" DESC`,
+		"created desc,name":   ` ORDER BY "u"."created_at" DESC, "u"."name" ASC`,
+		" name ASC , id Desc": ` ORDER BY "u"."name" ASC, "id" DESC`,
+	}
+	for requested, want := range tests {
+		if got, err := OrderBy(allowed, requested); err != nil || got != want {
+			t.Errorf("OrderBy(%q) = %q, %v, want %q", requested, got, err, want)
+		}
+	}
+}
+
+func TestOrderByRejectsUnknownSorts(t *testing.T) {
+	allowed := map[string]string{"name": "u.name", "created": "u.created_at"}
+	tests := map[string]string{
+		"password":               `invalid sort key "password": expected one of created, name`,
+		"name; DROP TABLE users": `invalid sort`,
+		"u.name":                 `invalid sort key "u.name"`,
+		"name sideways":          `invalid sort direction "sideways"`,
+		"-name desc":             `use either a +/- prefix or a direction`,
+		"name,":                  `expected a key with an optional direction`,
+		"name asc extra":         `expected a key with an optional direction`,
+		`name" DESC, "password`:  `invalid sort`,
+	}
+	for requested, want := range tests {
+		if got, err := OrderBy(allowed, requested); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("OrderBy(%q) = %q, %v, want an error containing %q", requested, got, err, want)
+		}
+	}
+}
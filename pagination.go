@@ -38,6 +38,7 @@ type Pagination[T any] interface {
 	Debug() Pagination[T]
 	Offset(ctx context.Context, req *RequestPaginationOffset) (*ResponsePaginationOffset[T], error)
 	Cursor(ctx context.Context, req *RequestPaginationCursor) (*ResponsePaginationCursor[T], error)
+	Paginate(ctx context.Context, query string, page, pageSize int, keyValuePairs ...any) (*ResponsePaginationOffset[T], error)
 	GetKvLimit(pageSize int) []any
 	GetKvOffset(page, pageSize int) []any
 }
@@ -92,8 +93,8 @@ func (p *pagination[T]) Offset(ctx context.Context, req *RequestPaginationOffset
 		Task(async.Bind(&total, func(ctx context.Context) (int64, error) {
 			var count int64
 			if req.QueryCount != "" {
-				_, err = p.postgres.Select(req.QueryCount, &count, req.Kv...).One(ctx)
-				if err != nil {
+				// Each task keeps its own err, as the tasks run concurrently
+				if _, err := p.postgres.Select(req.QueryCount, &count, req.Kv...).One(ctx); err != nil {
 					return 0, err
 				}
 			}
@@ -101,8 +102,7 @@ func (p *pagination[T]) Offset(ctx context.Context, req *RequestPaginationOffset
 		})).
 		Task(async.Bind(&items, func(ctx context.Context) ([]T, error) {
 			var data []T
-			_, err = p.postgres.Select(req.Query, &data, req.Kv...).Many(ctx)
-			if err != nil {
+			if _, err := p.postgres.Select(req.Query, &data, req.Kv...).Many(ctx); err != nil {
 				return nil, err
 			}
 			return data, nil
@@ -126,6 +126,23 @@ func (p *pagination[T]) Offset(ctx context.Context, req *RequestPaginationOffset
 	}, nil
 }
 
+// Paginate runs query for one page with LIMIT :limit OFFSET :offset appended, and counts the
+// total over the same filter with SELECT count(*) FROM (query), dropping a trailing ORDER BY.
+// query must not have its own LIMIT or OFFSET. page and pageSize are clamped like Offset.
+func (p *pagination[T]) Paginate(ctx context.Context, query string, page, pageSize int, keyValuePairs ...any) (*ResponsePaginationOffset[T], error) {
+	kv := append([]any(nil), keyValuePairs...)
+	kv = append(kv, p.GetKvLimit(pageSize)...)
+	kv = append(kv, p.GetKvOffset(page, pageSize)...)
+
+	return p.Offset(ctx, &RequestPaginationOffset{
+		Page:       page,
+		Size:       pageSize,
+		Query:      query + " LIMIT :limit OFFSET :offset",
+		QueryCount: countQuery(query),
+		Kv:         kv,
+	})
+}
+
 func (p *pagination[T]) Cursor(ctx context.Context, req *RequestPaginationCursor) (*ResponsePaginationCursor[T], error) {
 	if len(req.Sorts) == 0 {
 		return nil, errors.New("cursor sorts is required")
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"math"
+	"strings"
 )
 
 type RequestPaginationOffset struct {
@@ -22,3 +23,21 @@ type ResponsePaginationOffset[T any] struct {
 func (r *RequestPaginationOffset) GetTotalPages(total int64) int {
 	return int(math.Ceil(float64(total) / float64(r.Size)))
 }
+
+// countQuery derives a query counting the rows of query, without its top-level ORDER BY.
+func countQuery(query string) string {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+
+	// ORDER BY inside parentheses, such as in window functions or subqueries, is kept
+	var previous string
+	for word, position := nextTopLevelWord(query, 0); word != ""; word, position = nextTopLevelWord(query, position) {
+		if previous == "order" && word == "by" {
+			orderStart := strings.LastIndex(strings.ToLower(query[:position-len(word)]), "order")
+			query = strings.TrimSpace(query[:orderStart])
+			break
+		}
+		previous = word
+	}
+
+	return "SELECT count(*) FROM (" + query + ") AS paginate_count"
+}
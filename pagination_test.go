@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// usersTable answers count queries with total and other queries with the ids of the page
+// selected by their last two arguments, a limit and an offset. It records the count queries.
+type usersTable struct {
+	total int64
+
+	mu           sync.Mutex
+	countQueries []string
+}
+
+func (u *usersTable) handle(_ context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	if strings.Contains(query, "count(*)") {
+		u.mu.Lock()
+		u.countQueries = append(u.countQueries, query)
+		u.mu.Unlock()
+		return fakeResult{columns: []string{"count"}, rows: [][]driver.Value{{u.total}}}, nil
+	}
+
+	limit, offset := args[len(args)-2].Value.(int64), args[len(args)-1].Value.(int64)
+	result := fakeResult{columns: []string{"id"}}
+	for id := offset + 1; id <= min(offset+limit, u.total); id++ {
+		result.rows = append(result.rows, []driver.Value{id})
+	}
+	return result, nil
+}
+
+func TestPaginate(t *testing.T) {
+	table := &usersTable{total: 25}
+	pages := NewPagination[int64](newFakeDriver(table.handle).client(t))
+
+	for page, want := range map[int][]int64{
+		1: {1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		2: {11, 12, 13, 14, 15, 16, 17, 18, 19, 20},
+		3: {21, 22, 23, 24, 25},
+		4: {},
+	} {
+		response, err := pages.Paginate(context.Background(), "SELECT id FROM users WHERE active = :active ORDER BY id", page, 10, "active", true)
+		if err != nil {
+			t.Fatalf("Paginate(page %d): %v", page, err)
+		}
+		if response.Page != page || response.TotalItems != 25 || response.TotalPages != 3 || !equalInts(response.Items, want) {
+			t.Errorf("Paginate(page %d) = %+v, want page %d of 3 with 25 items in total and items %v", page, response, page, want)
+		}
+	}
+
+	for _, query := range table.countQueries {
+		if strings.Contains(query, "ORDER BY") {
+			t.Errorf("count query %q keeps the ORDER BY", query)
+		}
+	}
+}
+
+func TestPaginateClampsPageAndPageSize(t *testing.T) {
+	table := &usersTable{total: 25}
+	pages := NewPagination(newFakeDriver(table.handle).client(t), WithMinPageSize[int64](5), WithMaxPageSize[int64](20))
+
+	response, err := pages.Paginate(context.Background(), "SELECT id FROM users", 0, 100)
+	if err != nil {
+		t.Fatalf("Paginate: %v", err)
+	}
+	if response.Page != 1 || len(response.Items) != 20 || response.TotalPages != 2 {
+		t.Errorf("Paginate(page 0, size 100) = page %d with %d items of %d pages, want page 1 with 20 items of 2 pages", response.Page, len(response.Items), response.TotalPages)
+	}
+}
+
+func TestCountQuery(t *testing.T) {
+	tests := map[string]string{
+		"SELECT id FROM users ORDER BY id":                                  "SELECT count(*) FROM (SELECT id FROM users) AS paginate_count",
+		"SELECT id FROM users order by id desc;":                            "SELECT count(*) FROM (SELECT id FROM users) AS paginate_count",
+		"SELECT id, row_number() OVER (ORDER BY id) FROM users":             "SELECT count(*) FROM (SELECT id, row_number() OVER (ORDER BY id) FROM users) AS paginate_count",
+		"SELECT id FROM (SELECT id FROM users ORDER BY id LIMIT 5) AS top5": "SELECT count(*) FROM (SELECT id FROM (SELECT id FROM users ORDER BY id LIMIT 5) AS top5) AS paginate_count",
+	}
+	for query, want := range tests {
+		if got := countQuery(query); got != want {
+			t.Errorf("countQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func equalInts(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// namedParameter is a :name placeholder found in a query.
+type namedParameter struct {
+	name  string
+	start int // Position of the leading colon
+	end   int // Position after the last character of the name
+}
+
+// namedParameters returns the :name placeholders of query in order. String literals, quoted
+// identifiers, dollar-quoted strings, comments and :: casts are skipped, so 'a:b' and col::text
+// are not parameters. Like sqlx, a name is made of letters, digits, underscores and dots.
+func namedParameters(query string) []namedParameter {
+	var parameters []namedParameter
+	position := 0
+	for position < len(query) {
+		switch character := query[position]; {
+		case strings.HasPrefix(query[position:], "--"):
+			end := strings.IndexByte(query[position:], '\n')
+			if end < 0 {
+				return parameters
+			}
+			position += end + 1
+		case strings.HasPrefix(query[position:], "/*"):
+			position = skipBlockComment(query, position)
+		case character == '\'':
+			position = skipQuoted(query, position, isEscapeString(query, position))
+		case character == '"':
+			position = skipQuoted(query, position, false)
+		case character == '$':
+			position = skipDollarQuoted(query, position)
+		case strings.HasPrefix(query[position:], "::"):
+			position += 2
+		case character == ':':
+			start := position
+			position++
+			for position < len(query) && (isWordCharacter(query[position]) || query[position] == '.') {
+				position++
+			}
+			if position > start+1 {
+				parameters = append(parameters, namedParameter{name: query[start+1 : position], start: start, end: position})
+			}
+		default:
+			position++
+		}
+	}
+	return parameters
+}
+
+// escapeNamedQuery rewrites query so that sqlx binds the same parameters namedParameters finds.
+// sqlx reads a parameter at every colon, so the colons of literals, comments and :: casts are
+// doubled, which sqlx turns back into one, and a space separates a parameter from a cast as
+// in :id::text, which sqlx would otherwise reject.
+func escapeNamedQuery(query string) string {
+	if !strings.Contains(query, ":") {
+		return query
+	}
+
+	parameters := namedParameters(query)
+	var builder strings.Builder
+	builder.Grow(len(query) + 8)
+	position := 0
+	for _, parameter := range parameters {
+		builder.WriteString(strings.ReplaceAll(query[position:parameter.start], ":", "::"))
+		builder.WriteString(query[parameter.start:parameter.end])
+		if parameter.end < len(query) && query[parameter.end] == ':' {
+			builder.WriteByte(' ')
+		}
+		position = parameter.end
+	}
+	builder.WriteString(strings.ReplaceAll(query[position:], ":", "::"))
+	return builder.String()
+}
+
+// splitStatements splits query into its semicolon-separated statements, skipping semicolons
+// inside string literals, quoted identifiers, dollar-quoted strings and comments.
+// Statements are trimmed, and statements that are empty or only comments are dropped.
+func splitStatements(query string) []string {
+	var statements []string
+	start, position, content := 0, 0, false
+	for position < len(query) {
+		character := query[position]
+		switch {
+		case strings.HasPrefix(query[position:], "--"):
+			end := strings.IndexByte(query[position:], '\n')
+			if end < 0 {
+				position = len(query)
+			} else {
+				position += end + 1
+			}
+			continue
+		case strings.HasPrefix(query[position:], "/*"):
+			position = skipBlockComment(query, position)
+			continue
+		case character == ';':
+			if content {
+				statements = append(statements, strings.TrimSpace(query[start:position]))
+			}
+			position++
+			start, content = position, false
+			continue
+		case character == '\'':
+			position = skipQuoted(query, position, isEscapeString(query, position))
+		case character == '"':
+			position = skipQuoted(query, position, false)
+		case character == '$':
+			position = skipDollarQuoted(query, position)
+		default:
+			position++
+		}
+		content = content || !unicode.IsSpace(rune(character))
+	}
+	if content {
+		statements = append(statements, strings.TrimSpace(query[start:]))
+	}
+	return statements
+}
+
+// isEscapeString returns true if the quote at position opens an E'...' string, which allows
+// backslash escapes; standard strings only double the quote.
+func isEscapeString(query string, position int) bool {
+	return position > 0 && (query[position-1] == 'E' || query[position-1] == 'e') &&
+		(position == 1 || !isWordCharacter(query[position-2]))
+}
+
+// skipBlockComment returns the position after the possibly nested block comment starting at position.
+func skipBlockComment(query string, position int) int {
+	nesting := 0
+	for position < len(query) {
+		if strings.HasPrefix(query[position:], "/*") {
+			nesting++
+			position += 2
+		} else if strings.HasPrefix(query[position:], "*/") {
+			nesting--
+			position += 2
+			if nesting == 0 {
+				return position
+			}
+		} else {
+			position++
+		}
+	}
+	return position
+}
+
+// skipQuoted returns the position after the quoted string or identifier starting at position.
+// A doubled quote character is part of the string; a backslash escapes the next character if escaped is true.
+func skipQuoted(query string, position int, escaped bool) int {
+	quote := query[position]
+	position++
+	for position < len(query) {
+		switch query[position] {
+		case '\\':
+			if escaped {
+				position++
+			}
+		case quote:
+			if position+1 < len(query) && query[position+1] == quote {
+				position++
+			} else {
+				return position + 1
+			}
+		}
+		position++
+	}
+	return position
+}
+
+// skipDollarQuoted returns the position after the dollar-quoted string starting at position,
+// e.g. $$text$$ or $tag$text$tag$. A $ that does not open one, such as $1, is skipped alone.
+func skipDollarQuoted(query string, position int) int {
+	if position > 0 && isWordCharacter(query[position-1]) {
+		return position + 1
+	}
+	tagEnd := position + 1
+	for tagEnd < len(query) && query[tagEnd] != '$' {
+		character := query[tagEnd]
+		if character >= '0' && character <= '9' && tagEnd == position+1 || !isWordCharacter(character) {
+			return position + 1
+		}
+		tagEnd++
+	}
+	if tagEnd >= len(query) {
+		return position + 1
+	}
+
+	tag := query[position : tagEnd+1]
+	end := strings.Index(query[tagEnd+1:], tag)
+	if end < 0 {
+		return len(query)
+	}
+	return tagEnd + 1 + end + len(tag)
+}
+
+// checkArguments returns an error wrapping ErrMissingArguments, listing every named parameter
+// of query that has no key in arguments.
+func checkArguments(query string, arguments map[string]any) error {
+	var missing []string
+	seen := make(map[string]struct{})
+	for _, parameter := range namedParameters(query) {
+		if _, exists := arguments[parameter.name]; exists {
+			continue
+		}
+		if _, exists := seen[parameter.name]; !exists {
+			seen[parameter.name] = struct{}{}
+			missing = append(missing, ":"+parameter.name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return errors.Wrapf(ErrMissingArguments, "query %q needs %s", query, strings.Join(missing, ", "))
+}
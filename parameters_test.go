@@ -0,0 +1,177 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestNamedParameters(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT * FROM t WHERE a = :a AND b = :b_2", []string{"a", "b_2"}},
+		{"SELECT :user.id", []string{"user.id"}},
+		{"SELECT col::text FROM t WHERE id = :id", []string{"id"}},
+		{"SELECT :id::text", []string{"id"}},
+		{"SELECT 'a:b', E'it\\':s', 'x'':y' WHERE id = :id", []string{"id"}},
+		{`SELECT ":quoted" FROM t WHERE id = :id`, []string{"id"}},
+		{"SELECT $$:dollar$$, $tag$:tagged$tag$ WHERE id = :id", []string{"id"}},
+		{"SELECT 1 -- :comment\nWHERE id = :id /* :block /* :nested */ */", []string{"id"}},
+		{"SELECT $1 WHERE x := 1 AND id = :id", []string{"id"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, parameter := range namedParameters(test.query) {
+			got = append(got, parameter.name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("namedParameters(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestEscapeNamedQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // Query as compiled by sqlx for Postgres
+		names []string
+	}{
+		{"SELECT * FROM t WHERE id = :id", "SELECT * FROM t WHERE id = $1", []string{"id"}},
+		{"SELECT col::text FROM t WHERE id = :id", "SELECT col::text FROM t WHERE id = $1", []string{"id"}},
+		{"SELECT :id::text", "SELECT $1 ::text", []string{"id"}},
+		{"SELECT '10:30' WHERE id = :id", "SELECT '10:30' WHERE id = $1", []string{"id"}},
+		{"SELECT 1 -- at 10:30\nWHERE id = :id", "SELECT 1 -- at 10:30\nWHERE id = $1", []string{"id"}},
+		{"SELECT $$a::b$$, :a, :b", "SELECT $$a::b$$, $1, $2", []string{"a", "b"}},
+		{"SELECT 1", "SELECT 1", nil},
+	}
+	for _, test := range tests {
+		got, names, err := compileNamed(escapeNamedQuery(test.query))
+		if err != nil {
+			t.Errorf("compiling escapeNamedQuery(%q): %v", test.query, err)
+			continue
+		}
+		if got != test.want || !reflect.DeepEqual(names, test.names) {
+			t.Errorf("escapeNamedQuery(%q) compiles to %q with %v, want %q with %v", test.query, got, names, test.want, test.names)
+		}
+	}
+}
+
+// compileNamed compiles query with sqlx like a prepared named statement, returning the
+// positional query and the names bound to each position.
+func compileNamed(query string) (string, []string, error) {
+	arguments := make(map[string]any)
+	for _, parameter := range namedParameters(query) {
+		arguments[parameter.name] = parameter.name
+	}
+	compiled, values, err := sqlx.Named(query, arguments)
+	if err != nil {
+		return "", nil, err
+	}
+	var names []string
+	for _, value := range values {
+		names = append(names, value.(string))
+	}
+	return sqlx.Rebind(sqlx.DOLLAR, compiled), names, nil
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT 1; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT ';'; SELECT E'\\';'", []string{"SELECT ';'", "SELECT E'\\';'"}},
+		{`SELECT 1 AS ";"; SELECT $$;$$`, []string{`SELECT 1 AS ";"`, "SELECT $$;$$"}},
+		{"SELECT 1 -- ;\n; /* ; */ ;\n-- only a comment\n", []string{"SELECT 1 -- ;"}},
+		{" ; ; ", nil},
+		{"UPDATE t SET n = 1 WHERE id = :id", []string{"UPDATE t SET n = 1 WHERE id = :id"}},
+	}
+	for _, test := range tests {
+		if got := splitStatements(test.query); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitStatements(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestCheckArguments(t *testing.T) {
+	query := "SELECT ':ignored', col::text FROM t WHERE a = :a AND b = :b AND c = :c AND b2 = :b"
+	err := checkArguments(query, map[string]any{"a": 1})
+	if !errors.Is(err, ErrMissingArguments) {
+		t.Fatalf("checkArguments() = %v, want ErrMissingArguments", err)
+	}
+	if !strings.HasSuffix(err.Error(), "needs :b, :c: "+ErrMissingArguments.Error()) {
+		t.Errorf("checkArguments() = %q, want :b and :c listed once", err)
+	}
+	if err = checkArguments(query, map[string]any{"a": 1, "b": 2, "c": 3}); err != nil {
+		t.Errorf("checkArguments() with every key = %v", err)
+	}
+}
+
+func TestBatchBindsLikeCheck(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+		bound    [][]driver.NamedValue
+	)
+	db := newFakeDriver(func(_ context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, query)
+		bound = append(bound, args)
+		return fakeResult{rowsAffected: 1}, nil
+	}).client(t)
+
+	results, err := db.Batch(context.Background(), `
+		UPDATE jobs SET note = 'due 10:30; later', due = :due::date WHERE id = :id;
+		UPDATE jobs SET total = total::numeric + :amount WHERE id = :id;
+	`, map[string]any{"due": "2026-01-02", "id": 7, "amount": 3})
+	if err != nil {
+		t.Fatalf("Batch: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Batch returned %d results, want 2", len(results))
+	}
+
+	want := []string{
+		"UPDATE jobs SET note = 'due 10:30; later', due = $1 ::date WHERE id = $2",
+		"UPDATE jobs SET total = total::numeric + $1 WHERE id = $2",
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("Batch sent %q, want %q", received, want)
+	}
+	wantValues := [][]any{{"2026-01-02", int64(7)}, {int64(3), int64(7)}}
+	for i, args := range bound {
+		var values []any
+		for _, arg := range args {
+			values = append(values, arg.Value)
+		}
+		if !reflect.DeepEqual(values, wantValues[i]) {
+			t.Errorf("statement %d bound %v, want %v", i, values, wantValues[i])
+		}
+	}
+}
+
+func TestCastsAndLiteralsAreNotBound(t *testing.T) {
+	recorder := &queryRecorder{rowsAffected: 1}
+	db := newFakeDriver(recorder.handle).client(t, WithMaxOpenConns(1))
+
+	_, err := db.Update("UPDATE events SET label = 'a:b', at = :at::timestamptz WHERE id::text = :id", "at", "2026-01-02 10:30", "id", "7").
+		ExecUpdate(context.Background())
+	if err != nil {
+		t.Fatalf("ExecUpdate: %v", err)
+	}
+	if want := "UPDATE events SET label = 'a:b', at = $1 ::timestamptz WHERE id::text = $2"; recorder.queries[0] != want {
+		t.Errorf("query = %s, want %s", recorder.queries[0], want)
+	}
+	if want := []any{"2026-01-02 10:30", "7"}; !reflect.DeepEqual(recorder.args[0], want) {
+		t.Errorf("arguments = %v, want %v", recorder.args[0], want)
+	}
+}
@@ -3,52 +3,96 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
 // pipeline represents a sequence of database queries that will be executed in a transaction.
 // It maintains the order of queries and handles parameter resolution between queries.
+//
+// All pipeline operations are guarded by a mutex, so a pipeline shared by several
+// goroutines will not corrupt its internal state. Building a pipeline from several
+// goroutines at once still yields a non-deterministic query order, so builders should
+// be owned by a single goroutine.
 type pipeline struct {
-	queryParameters map[string][]any // Query to parameters mapping
-	queryKeys       []string         // Ordered list of queries
+	mu                sync.Mutex
+	queryParameters   map[string][]any    // Query to parameters mapping
+	queryDestinations map[string]any      // Select query to destination mapping
+	queryLabels       map[string]string   // Query to user-defined label mapping
+	queryOptional     map[string]struct{} // Queries allowed to fail without aborting the transaction
+	queryNoReturn     map[string]struct{} // Inserts reporting affected rows instead of a returned ID
+	queryKeys         []string            // Ordered list of queries
+}
+
+// batchStep is a single resolved pipeline query queued into a driver batch.
+type batchStep struct {
+	name      string // Step description for error messages, see describeStep
+	query     string
+	queryType string
+	returning bool
+	arguments map[string]any
 }
 
+// uniqueSuffixRegex matches the comment uniqueQuery appends to repeated queries.
+var uniqueSuffixRegex = regexp.MustCompile(`/\*[0-9]+\*/$`)
+
+// stepSnippetLength is the number of characters of a query shown in pipeline error messages.
+const stepSnippetLength = 60
+
 // NewPipeline creates a new empty pipeline instance.
 // The pipeline can be used to chain multiple database operations
 // that should be executed atomically in a transaction.
 func NewPipeline() *pipeline {
 	return &pipeline{
-		queryParameters: make(map[string][]any),
-		queryKeys:       make([]string, 0),
+		queryParameters:   make(map[string][]any),
+		queryDestinations: make(map[string]any),
+		queryLabels:       make(map[string]string),
+		queryOptional:     make(map[string]struct{}),
+		queryNoReturn:     make(map[string]struct{}),
+		queryKeys:         make([]string, 0),
 	}
 }
 
 // runPipeline executes all queries in the pipeline within the provided transaction.
 // It processes queries in the order they were added and resolves parameter dependencies
-// between queries using the qResult hook mechanism.
+// between queries using the result hook mechanism.
+//
+// The context deadline is the budget for the whole pipeline: once it is exceeded, even in the
+// middle of a query, execution stops and the returned error wraps context.DeadlineExceeded.
+//
+// Optional queries run inside a savepoint. If one fails, the transaction is rolled back
+// to the savepoint and execution continues with the next query; the failed query has no result.
 //
 // Parameters:
 //   - ctx: Context for cancellation and timeout
+//   - postgresInstance: Client used for logging
 //   - tx: Database transaction
 //   - debug: Enable debug logging for queries
+//   - shared: Arguments merged into the arguments of every query, see WithSharedArgs
 //
 // Returns:
 //   - *ExecResult: Contains the results and IDs from executed queries
 //   - error: Any error that occurred during execution
-func (p *pipeline) runPipeline(ctx context.Context, tx *sqlx.Tx, debug bool) (*ExecResult, error) {
+func (p *pipeline) runPipeline(ctx context.Context, postgresInstance *postgres, tx *sqlx.Tx, debug bool, shared map[string]any) (*ExecResult, error) {
 	if tx == nil {
 		return nil, fmt.Errorf("transaction cannot be nil")
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := newExecResult(len(p.queryKeys))
 	if len(p.queryKeys) == 0 {
-		return &ExecResult{ids: make(map[string]any)}, nil
+		return result, nil
 	}
 
-	result := &ExecResult{
-		ids: make(map[string]any, len(p.queryKeys)),
-	}
+	// Steps of the same shape, e.g. the inserts of a loop, prepare their statement once
+	statements := newReusedStatements(tx)
+	defer statements.close()
 
 	for index, query := range p.queryKeys {
 		// Check context cancellation
@@ -60,41 +104,184 @@ func (p *pipeline) runPipeline(ctx context.Context, tx *sqlx.Tx, debug bool) (*E
 
 		parameters, exists := p.queryParameters[query]
 		if !exists {
-			return nil, fmt.Errorf("query parameters not found for query at index %d", index)
+			return nil, fmt.Errorf("query parameters not found for %s", p.describeStepLocked(index, query))
 		}
 
-		arguments, err := PairsHook(parameters, result.ids, qResult)
+		arguments, err := pairsHookWithMapper(parameters, result.ids, postgresInstance.resultHook, postgresInstance.fieldMapper)
 		if err != nil {
-			return nil, fmt.Errorf("failed to resolve parameters for query at index %d: %w", index, err)
+			return nil, fmt.Errorf("failed to resolve parameters for %s: %w", p.describeStepLocked(index, query), err)
 		}
+		statement, arguments := expandInClauses(query, mergeArguments(shared, arguments))
 
 		// Debug transaction query if enabled
-		if debug {
-			debugQuery(query, arguments)
+		postgresInstance.beforeQuery(ctx, debug, statement, arguments)
+
+		_, optional := p.queryOptional[query]
+		savepoint := fmt.Sprintf("pipeline_step_%d", index)
+		if optional {
+			if _, err = tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+				return nil, fmt.Errorf("failed to create savepoint for %s: %w", p.describeStepLocked(index, query), err)
+			}
 		}
 
-		var queryID any
+		started := time.Now()
+		var (
+			queryID      any
+			rowsAffected int64
+		)
 		queryType := queryType(query)
+		destination, isSelect := p.queryDestinations[query]
+		_, noReturn := p.queryNoReturn[query]
 
 		switch {
-		case strings.EqualFold(queryType, qInsert):
-			queryID, err = insertTx(ctx, tx, query, arguments)
+		case isSelect:
+			queryID, err = selectTx(ctx, statements, statement, arguments, destination)
+			rowsAffected = selectedRows(queryID != nil, destination)
+		case strings.EqualFold(queryType, qInsert) && !noReturn:
+			queryID, err = insertTx(ctx, statements, statement, arguments)
+			rowsAffected = 1
+		case hasReturning(statement):
+			// UPDATE/DELETE/MERGE ... RETURNING feeds the returned value to later queries instead of the row count
+			queryID, err = returningTx(ctx, statements, statement, arguments)
+			if queryID != nil {
+				rowsAffected = 1
+			}
 		case strings.EqualFold(queryType, qDelete):
-			queryID, err = deleteTx(ctx, tx, query, arguments)
+			rowsAffected, err = deleteTx(ctx, statements, statement, arguments)
+			queryID = rowsAffected
 		default:
-			queryID, err = updateTx(ctx, tx, query, arguments)
+			rowsAffected, err = updateTx(ctx, statements, statement, arguments)
+			queryID = rowsAffected
+		}
+		if err != nil {
+			rowsAffected = 0
+		}
+		duration := postgresInstance.afterQuery(ctx, statement, arguments, started, rowsAffected, err)
+
+		// A step cut short by the deadline fails with a driver cancellation error; report the
+		// context error instead, and never treat it as a skippable optional failure
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("pipeline interrupted at %s: %w", p.describeStepLocked(index, query), ctxErr)
+		}
+
+		if optional {
+			if err != nil {
+				if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rollbackErr != nil {
+					return nil, fmt.Errorf("failed to roll back to savepoint for %s: %w", p.describeStepLocked(index, query), rollbackErr)
+				}
+				continue
+			}
+			if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+				return nil, fmt.Errorf("failed to release savepoint for %s: %w", p.describeStepLocked(index, query), err)
+			}
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to execute query at index %d: %w", index, err)
+			return nil, fmt.Errorf("failed to execute %s: %w", p.describeStepLocked(index, query), err)
 		}
 
-		result.ids[query] = queryID
+		result.set(query, p.queryLabels[query], queryID, rowsAffected, duration)
 	}
 
 	return result, nil
 }
 
+// runBatch executes all queries in the pipeline as a single driver batch on conn.
+// The caller must have opened the pipeline transaction on conn and must only use this
+// for pipelines without result dependencies, since no step can see an earlier step's result.
+func (p *pipeline) runBatch(ctx context.Context, postgresInstance *postgres, conn *sqlx.Conn, debug bool, shared map[string]any) (*ExecResult, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("connection cannot be nil")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := newExecResult(len(p.queryKeys))
+	if len(p.queryKeys) == 0 {
+		return result, nil
+	}
+
+	steps := make([]batchStep, 0, len(p.queryKeys))
+	for index, query := range p.queryKeys {
+		parameters, exists := p.queryParameters[query]
+		if !exists {
+			return nil, fmt.Errorf("query parameters not found for %s", p.describeStepLocked(index, query))
+		}
+
+		arguments, err := pairsWithMapper(parameters, postgresInstance.fieldMapper)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve parameters for %s: %w", p.describeStepLocked(index, query), err)
+		}
+		statement, arguments := expandInClauses(query, mergeArguments(shared, arguments))
+
+		postgresInstance.beforeQuery(ctx, debug, statement, arguments)
+
+		stepType := queryType(query)
+		if _, noReturn := p.queryNoReturn[query]; noReturn {
+			// Without a returned ID the insert runs as an exec reporting affected rows
+			stepType = qUpdate
+		}
+		steps = append(steps, batchStep{
+			name:      p.describeStepLocked(index, query),
+			query:     statement,
+			queryType: stepType,
+			returning: hasReturning(statement),
+			arguments: arguments,
+		})
+	}
+
+	started := time.Now()
+	ids, err := sendBatch(ctx, conn, steps)
+	durations := make([]time.Duration, len(steps))
+	rowsAffected := make([]int64, len(steps))
+	for index, step := range steps {
+		// Steps share one round-trip, so each reports the duration of the whole batch
+		if err == nil {
+			if count, ok := ids[index].(int64); ok && step.queryType != qInsert && !step.returning {
+				rowsAffected[index] = count
+			} else if ids[index] != nil {
+				rowsAffected[index] = 1
+			}
+		}
+		durations[index] = postgresInstance.afterQuery(ctx, step.query, step.arguments, started, rowsAffected[index], err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute pipeline batch: %w", err)
+	}
+
+	for index, query := range p.queryKeys {
+		result.set(query, p.queryLabels[query], ids[index], rowsAffected[index], durations[index])
+	}
+
+	return result, nil
+}
+
+// batchable returns true if the pipeline can be sent as a single batch: no query references
+// the result of another query through the result hook, no query selects into a destination
+// and no query is optional.
+func (p *pipeline) batchable(hook string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.queryDestinations) > 0 || len(p.queryOptional) > 0 {
+		return false
+	}
+
+	for _, parameters := range p.queryParameters {
+		parameters, err := singleArgumentPairs(parameters, nil)
+		if err != nil {
+			return false
+		}
+		for i := 1; i < len(parameters); i += 2 {
+			if stringValue, ok := parameters[i].(string); ok && strings.HasPrefix(stringValue, hook) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // addPipeline adds a query with its parameters to the end of the pipeline.
 // If the same query already exists, it will be made unique by appending a comment.
 //
@@ -106,8 +293,35 @@ func (p *pipeline) addPipeline(query string, keyValuePairs []any) {
 		return
 	}
 
-	uniqueQuery := p.uniqueQuery(query)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	uniqueQuery := p.uniqueQueryLocked(query)
+	p.queryParameters[uniqueQuery] = keyValuePairs
+	p.queryKeys = append(p.queryKeys, uniqueQuery)
+}
+
+// addSelectPipeline adds a select query to the end of the pipeline.
+// When the pipeline runs, the selected rows are scanned into destination and the
+// selected value can be referenced by later queries through FromResult.
+//
+// Parameters:
+//   - query: The SQL select query to add
+//   - destination: Pointer to a slice for many rows, or to a struct or scalar for one row
+//   - keyValuePairs: Key-value pairs for query parameters
+func (p *pipeline) addSelectPipeline(query string, destination any, keyValuePairs []any) {
+	if query == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	uniqueQuery := p.uniqueQueryLocked(query)
 	p.queryParameters[uniqueQuery] = keyValuePairs
+	if destination != nil {
+		p.queryDestinations[uniqueQuery] = destination
+	}
 	p.queryKeys = append(p.queryKeys, uniqueQuery)
 }
 
@@ -117,70 +331,323 @@ func (p *pipeline) addPipeline(query string, keyValuePairs []any) {
 // Parameters:
 //   - query: The SQL query to add at the beginning
 //   - keyValuePairs: Key-value pairs for query parameters
-func (p *pipeline) addFirstPipeline(query string, keyValuePairs []any) {
+//   - label: Optional label to reference the query result by, empty for none
+//
+// Returns the key the query was stored under, or an empty string if query is empty.
+func (p *pipeline) addFirstPipeline(query string, keyValuePairs []any, label string) string {
 	if query == "" {
-		return
+		return ""
 	}
 
-	uniqueQuery := p.uniqueQuery(query)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	uniqueQuery := p.uniqueQueryLocked(query)
 	p.queryParameters[uniqueQuery] = keyValuePairs
+	if label != "" {
+		p.queryLabels[uniqueQuery] = label
+	}
 	p.queryKeys = append([]string{uniqueQuery}, p.queryKeys...)
+	return uniqueQuery
+}
+
+// labelLast labels the most recently added query so its result can be fetched
+// with TxResult(label) and referenced with FromResult(label).
+// It returns false if the pipeline is empty.
+func (p *pipeline) labelLast(label string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.queryKeys) == 0 {
+		return false
+	}
+	p.queryLabels[p.queryKeys[len(p.queryKeys)-1]] = label
+	return true
+}
+
+// markOptional marks the query stored under key as optional.
+func (p *pipeline) markOptional(key string) {
+	if key == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queryOptional[key] = struct{}{}
+}
+
+// markNoReturn marks the insert stored under key as reporting affected rows instead of a returned ID.
+func (p *pipeline) markNoReturn(key string) {
+	if key == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queryNoReturn[key] = struct{}{}
+}
+
+// markLastOptional marks the most recently added query as optional.
+// It returns false if the pipeline is empty.
+func (p *pipeline) markLastOptional() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.queryKeys) == 0 {
+		return false
+	}
+	p.queryOptional[p.queryKeys[len(p.queryKeys)-1]] = struct{}{}
+	return true
+}
+
+// markLastNoReturn marks the most recently added query as reporting affected rows instead of a returned ID.
+// It returns false if the pipeline is empty.
+func (p *pipeline) markLastNoReturn() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.queryKeys) == 0 {
+		return false
+	}
+	p.queryNoReturn[p.queryKeys[len(p.queryKeys)-1]] = struct{}{}
+	return true
 }
 
 // appendPipeline merges another pipeline into the current one.
-// All queries from the source pipeline are added to the end of the current pipeline.
-// Query uniqueness is maintained during the merge process.
+// All queries from the source pipeline are added to the end of the current pipeline, in order.
+// Query uniqueness is maintained during the merge process: a query whose text is already used,
+// or is in reserved, is stored under a new key, and source parameters referencing it through
+// the hook prefix are rewritten to the new key. The source pipeline is not modified.
 //
 // Parameters:
 //   - sourcePipeline: The pipeline to append to the current one
-func (p *pipeline) appendPipeline(sourcePipeline *pipeline) {
-	if sourcePipeline == nil || len(sourcePipeline.queryKeys) == 0 {
+//   - hook: Prefix marking FromResult references in parameters
+//   - reserved: Keys that will be used by queries added later, such as the exec's own query
+func (p *pipeline) appendPipeline(sourcePipeline *pipeline, hook string, reserved ...string) {
+	if sourcePipeline == nil || sourcePipeline == p {
 		return
 	}
 
+	// Snapshot the source under its own lock so the two pipelines are never locked together
+	sourcePipeline.mu.Lock()
+	sourceKeys := append([]string(nil), sourcePipeline.queryKeys...)
+	sourceParameters := make(map[string][]any, len(sourcePipeline.queryParameters))
+	for query, parameters := range sourcePipeline.queryParameters {
+		sourceParameters[query] = parameters
+	}
+	sourceDestinations := make(map[string]any, len(sourcePipeline.queryDestinations))
+	for query, destination := range sourcePipeline.queryDestinations {
+		sourceDestinations[query] = destination
+	}
+	sourceLabels := make(map[string]string, len(sourcePipeline.queryLabels))
+	for query, label := range sourcePipeline.queryLabels {
+		sourceLabels[query] = label
+	}
+	sourceOptional := make(map[string]struct{}, len(sourcePipeline.queryOptional))
+	for query := range sourcePipeline.queryOptional {
+		sourceOptional[query] = struct{}{}
+	}
+	sourceNoReturn := make(map[string]struct{}, len(sourcePipeline.queryNoReturn))
+	for query := range sourcePipeline.queryNoReturn {
+		sourceNoReturn[query] = struct{}{}
+	}
+	sourcePipeline.mu.Unlock()
+
+	if len(sourceKeys) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// Pre-allocate slice capacity for better performance
-	if cap(p.queryKeys) < len(p.queryKeys)+len(sourcePipeline.queryKeys) {
-		newQueryKeys := make([]string, len(p.queryKeys), len(p.queryKeys)+len(sourcePipeline.queryKeys))
+	if cap(p.queryKeys) < len(p.queryKeys)+len(sourceKeys) {
+		newQueryKeys := make([]string, len(p.queryKeys), len(p.queryKeys)+len(sourceKeys))
 		copy(newQueryKeys, p.queryKeys)
 		p.queryKeys = newQueryKeys
 	}
 
-	for _, query := range sourcePipeline.queryKeys {
+	// Reserve the keys while assigning new ones, so no source query takes them
+	for _, key := range reserved {
+		if _, exists := p.queryParameters[key]; key != "" && !exists {
+			p.queryParameters[key] = nil
+			defer delete(p.queryParameters, key)
+		}
+	}
+
+	renamed := make(map[string]string)
+	uniqueKeys := make([]string, len(sourceKeys))
+	for i, query := range sourceKeys {
+		uniqueKeys[i] = p.uniqueQueryLocked(query)
+		if uniqueKeys[i] != query {
+			renamed[query] = uniqueKeys[i]
+		}
+		p.queryParameters[uniqueKeys[i]] = nil
+	}
+
+	for i, query := range sourceKeys {
 		originalQuery := query
-		uniqueQuery := p.uniqueQuery(query)
+		uniqueQuery := uniqueKeys[i]
 
 		// Copy parameters from source pipeline
-		if parameters, exists := sourcePipeline.queryParameters[originalQuery]; exists {
-			p.queryParameters[uniqueQuery] = parameters
+		if parameters, exists := sourceParameters[originalQuery]; exists {
+			p.queryParameters[uniqueQuery] = renameResultReferences(parameters, renamed, hook)
+			if destination, isSelect := sourceDestinations[originalQuery]; isSelect {
+				p.queryDestinations[uniqueQuery] = destination
+			}
+			if label, labeled := sourceLabels[originalQuery]; labeled {
+				p.queryLabels[uniqueQuery] = label
+			}
+			if _, optional := sourceOptional[originalQuery]; optional {
+				p.queryOptional[uniqueQuery] = struct{}{}
+			}
+			if _, noReturn := sourceNoReturn[originalQuery]; noReturn {
+				p.queryNoReturn[uniqueQuery] = struct{}{}
+			}
 			p.queryKeys = append(p.queryKeys, uniqueQuery)
+		} else {
+			delete(p.queryParameters, uniqueQuery)
+		}
+	}
+}
+
+// renameResultReferences returns keyValuePairs with every FromResult reference to a renamed
+// query pointing to its new key. keyValuePairs is returned unchanged if nothing references one.
+func renameResultReferences(keyValuePairs []any, renamed map[string]string, hook string) []any {
+	if len(renamed) == 0 || hook == "" {
+		return keyValuePairs
+	}
+
+	pairs, err := singleArgumentPairs(keyValuePairs, nil)
+	if err != nil {
+		return keyValuePairs
+	}
+	var rewritten []any
+	for i := 1; i < len(pairs); i += 2 {
+		stringValue, ok := pairs[i].(string)
+		if !ok || !strings.HasPrefix(stringValue, hook) {
+			continue
+		}
+		newKey, exists := renamed[stringValue[len(hook):]]
+		if !exists {
+			continue
+		}
+		if rewritten == nil {
+			rewritten = append([]any(nil), pairs...)
+		}
+		rewritten[i] = hook + newKey
+	}
+	if rewritten == nil {
+		return keyValuePairs
+	}
+	return rewritten
+}
+
+// missingWhereQuery returns the first UPDATE or DELETE in the pipeline without a WHERE clause, if any.
+func (p *pipeline) missingWhereQuery() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, query := range p.queryKeys {
+		if missesWhere(query) {
+			return query, true
+		}
+	}
+	return "", false
+}
+
+// checkArguments returns an error for the first query whose named parameters are not all
+// covered by its key-value pairs or shared. It runs before the pipeline transaction starts.
+func (p *pipeline) checkArguments(shared map[string]any, fieldMapper func(string) string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for index, query := range p.queryKeys {
+		arguments, err := pairsWithMapper(p.queryParameters[query], fieldMapper)
+		if err != nil {
+			return fmt.Errorf("failed to resolve parameters for %s: %w", p.describeStepLocked(index, query), err)
+		}
+		if err = checkArguments(query, mergeArguments(shared, arguments)); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 // isTrans returns true if the pipeline contains at least one query.
 // This is used to determine if a transaction should be started.
 func (p *pipeline) isTrans() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	return len(p.queryKeys) > 0
 }
 
 // Len returns the number of queries in the pipeline.
 func (p *pipeline) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	return len(p.queryKeys)
 }
 
 // Clear removes all queries from the pipeline, resetting it to an empty state.
 func (p *pipeline) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.queryParameters = make(map[string][]any)
+	p.queryDestinations = make(map[string]any)
+	p.queryLabels = make(map[string]string)
+	p.queryOptional = make(map[string]struct{})
+	p.queryNoReturn = make(map[string]struct{})
 	p.queryKeys = p.queryKeys[:0] // Keep underlying array but reset length
 }
 
 // uniqueQuery ensures query uniqueness by appending a comment with an index
-// if the same query already exists in the pipeline.
+// if the same query already exists in the pipeline. The index is increased until
+// the resulting key is not used by any query in the pipeline.
 //
 // This prevents map key collisions while maintaining query functionality.
 func (p *pipeline) uniqueQuery(query string) string {
-	if _, exists := p.queryParameters[query]; exists {
-		query = fmt.Sprintf("%s/*%d*/", query, len(p.queryKeys))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.uniqueQueryLocked(query)
+}
+
+// describeStepLocked names the query at index for error messages, with its label if it has one.
+// The caller must hold the pipeline lock.
+func (p *pipeline) describeStepLocked(index int, query string) string {
+	return describeStep(index, query, p.queryLabels[query])
+}
+
+// describeStep names a pipeline step for error messages by its index, its label set with As,
+// if any, and the start of its query, e.g. query at index 2 "create_order" (INSERT INTO orders ...).
+func describeStep(index int, query, label string) string {
+	snippet := strings.Join(strings.Fields(uniqueSuffixRegex.ReplaceAllString(query, "")), " ")
+	if runes := []rune(snippet); len(runes) > stepSnippetLength {
+		snippet = string(runes[:stepSnippetLength]) + "..."
+	}
+	if label != "" {
+		return fmt.Sprintf("query at index %d %q (%s)", index, label, snippet)
+	}
+	return fmt.Sprintf("query at index %d (%s)", index, snippet)
+}
+
+// uniqueQueryLocked is uniqueQuery for callers that already hold the pipeline lock.
+func (p *pipeline) uniqueQueryLocked(query string) string {
+	if _, exists := p.queryParameters[query]; !exists {
+		return query
+	}
+
+	// The index alone can repeat after merges, so keep counting until the key is free
+	for index := len(p.queryKeys); ; index++ {
+		candidate := fmt.Sprintf("%s/*%d*/", query, index)
+		if _, exists := p.queryParameters[candidate]; !exists {
+			return candidate
+		}
 	}
-	return query
 }
@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// TestExecConcurrentPipeline runs one built Exec from several goroutines at once.
+// Run with -race: each ExecInTx runs a snapshot of the steps, so the Exec is only read.
+func TestExecConcurrentPipeline(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t)
+
+	exec := db.Insert("INSERT INTO orders (item) VALUES (:item)", "item", "book").NoReturn().
+		Insert("INSERT INTO order_lines (item) VALUES (:item) RETURNING id", "item", "book").
+		Update("UPDATE orders SET lines = lines + 1 WHERE item = :item", "item", "book")
+
+	const goroutines = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	results := make([]*ExecResult, goroutines)
+	for i := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := exec.ExecInTx(context.Background())
+			if err != nil {
+				errs <- err
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("ExecInTx: %v", err)
+	}
+
+	if queries := fake.queries.Load(); queries != goroutines*3 {
+		t.Errorf("%d runs of 3 steps ran %d queries, want %d", goroutines, queries, goroutines*3)
+	}
+	for i, result := range results {
+		if result != nil && len(result.ids) != 3 {
+			t.Errorf("run %d has results for %d steps, want 3", i, len(result.ids))
+		}
+	}
+	if got := exec.(*execQuery).pipeline.Len(); got != 2 {
+		t.Errorf("pipeline has %d steps after running, want the 2 added ones", got)
+	}
+}
+
+func TestExecInTxRunsTwiceUnchanged(t *testing.T) {
+	server := &sequenceServer{}
+	db := newFakeDriver(server.handle).client(t)
+
+	const insertOrder = "INSERT INTO orders (item) VALUES (:item) RETURNING id"
+	exec := db.Insert(insertOrder, "item", "book")
+	exec.Insert("INSERT INTO order_lines (order_id) VALUES (:order_id)", "order_id", exec.FromResult(insertOrder)).NoReturn()
+
+	for run := range 2 {
+		if _, err := exec.ExecInTx(context.Background()); err != nil {
+			t.Fatalf("run %d: ExecInTx: %v", run, err)
+		}
+	}
+
+	wantArgs := [][]any{{"book"}, {int64(1)}, {"book"}, {int64(2)}}
+	if !reflect.DeepEqual(server.args, wantArgs) {
+		t.Errorf("two runs bound %v in %q, want %v", server.args, server.queries, wantArgs)
+	}
+}
+
+// repeatedInserts builds a pipeline of count inserts of the same query.
+func repeatedInserts(db *postgres, count int) Exec {
+	const query = "INSERT INTO events (n) VALUES (:n) RETURNING id"
+	exec := db.Insert(query, "n", 0)
+	for i := 1; i < count; i++ {
+		exec.Insert(query, "n", i)
+	}
+	return exec
+}
+
+func TestPipelinePreparesRepeatedQueryOnce(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t)
+
+	if _, err := repeatedInserts(db, 100).ExecInTx(context.Background()); err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+	if prepares := fake.prepares.Load(); prepares != 1 {
+		t.Errorf("100 identical inserts prepared %d statements, want 1", prepares)
+	}
+	if queries := fake.queries.Load(); queries != 100 {
+		t.Errorf("100 identical inserts ran %d queries, want 100", queries)
+	}
+	if closes := fake.closes.Load(); closes != fake.prepares.Load() {
+		t.Errorf("closed %d of %d prepared statements at the end of the pipeline", closes, fake.prepares.Load())
+	}
+}
+
+// BenchmarkPipelineRepeatedInserts runs a pipeline of 100 identical inserts and reports the
+// statements prepared per pipeline, which stays at 1 because the steps share a statement.
+func BenchmarkPipelineRepeatedInserts(b *testing.B) {
+	fake := newFakeDriver(nil)
+	db := fake.client(b)
+	ctx := context.Background()
+
+	for b.Loop() {
+		if _, err := repeatedInserts(db, 100).ExecInTx(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(fake.prepares.Load())/float64(b.N), "prepares/op")
+}
+
+// BenchmarkTxStatements compares 100 identical inserts in a transaction with the statement
+// reused, as in a pipeline, to preparing it for each insert.
+func BenchmarkTxStatements(b *testing.B) {
+	for _, mode := range []struct {
+		name       string
+		statements func(tx *sqlx.Tx) *txStatements
+	}{
+		{"reused", newReusedStatements},
+		{"single-use", func(tx *sqlx.Tx) *txStatements { return &txStatements{tx: tx} }},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			fake := newFakeDriver(nil)
+			database := fake.db(b)
+			ctx := context.Background()
+
+			for b.Loop() {
+				tx, err := database.BeginTxx(ctx, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				statements := mode.statements(tx)
+				for i := range 100 {
+					if _, err = insertTx(ctx, statements, "INSERT INTO events (n) VALUES (:n) RETURNING id", map[string]any{"n": i}); err != nil {
+						b.Fatal(err)
+					}
+				}
+				statements.close()
+				if err = tx.Commit(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(fake.prepares.Load())/float64(b.N), "prepares/op")
+		})
+	}
+}
+
+// sleepingSteps answers queries calling pg_sleep only when ctx is done, with the cancellation
+// error of the server, and other queries like returningOne. It counts the other queries.
+func sleepingSteps(others *atomic.Int64) fakeHandler {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		if !strings.Contains(query, "pg_sleep") {
+			others.Add(1)
+			return returningOne(ctx, query, args)
+		}
+		select {
+		case <-ctx.Done():
+			return fakeResult{}, &pq.Error{Code: sqlStateQueryCanceled, Message: "canceling statement due to user request"}
+		case <-time.After(10 * time.Second):
+			return fakeResult{rowsAffected: 1}, nil
+		}
+	}
+}
+
+func TestPipelineStopsAtDeadlineMidStep(t *testing.T) {
+	var others atomic.Int64
+	db := newFakeDriver(sleepingSteps(&others)).client(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := db.Insert("INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book").
+		Update("UPDATE orders SET checked = pg_sleep(:seconds) IS NULL", "seconds", 10).As("slow check").
+		Update("UPDATE stock SET count = count - 1 WHERE item = :item", "item", "book").
+		ExecInTx(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "slow check") {
+		t.Errorf("ExecInTx = %v, want a deadline error naming the slow step", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("ExecInTx returned after %v, want it to stop at the deadline", elapsed)
+	}
+	if got := others.Load(); got != 1 {
+		t.Errorf("%d other steps ran, want only the step before the slow one", got)
+	}
+}
@@ -1,23 +1,75 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // postgres is the postgres database client.
 type postgres struct {
-	database *sqlx.DB
+	database           *sqlx.DB
+	driverName         string
+	withoutStackTraces bool
+	logger             Logger
+	observer           Observer
+	sampleRate         float64
+	sampling           bool
+	tracer             trace.Tracer
+	contextFieldsFunc  func(ctx context.Context) map[string]any
+	redactedKeys       map[string]struct{}
+	resultHook         string
+	fieldMapper        func(string) string // Keys untagged fields of struct arguments, nil to lower-case them
+	requireWhere       bool
+	defaultTimeout     time.Duration
+	statements         *statementCache // nil when the statement cache is disabled
+	replicas           []*sqlx.DB      // Read replicas, empty when reads go to the primary
+	nextReplica        atomic.Uint64
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // Postgres is the interface for the postgres database client.
+// It is implemented by this package and gains methods as features are added, which breaks
+// implementations outside of it; see Breaking Changes in the README.
 type Postgres interface {
 	Select(query string, destination any, keyValuePairs ...any) Select
+	SelectPositional(query string, destination any, arguments ...any) Select
 	Insert(query string, keyValuePairs ...any) Exec
 	Update(query string, keyValuePairs ...any) Exec
 	Delete(query string, keyValuePairs ...any) Exec
 	FromResult(from string) string
+	CopyTo(ctx context.Context, w io.Writer, query string, opts ...CopyOption) (int64, error)
+	CopyFrom(ctx context.Context, table string, columns []string, rows [][]any) (int64, error)
+	Ping(ctx context.Context) error
+	Stats() sql.DBStats
+	Scalar(ctx context.Context, query string, destination any, keyValuePairs ...any) error
+	Close() error
+	BulkInsert(ctx context.Context, table string, rows []map[string]any) (int64, error)
+	DB() *sqlx.DB
+	UpdateReturning(ctx context.Context, query string, destination any, keyValuePairs ...any) (int64, error)
+	Count(ctx context.Context, query string, keyValuePairs ...any) (int64, error)
+	Exists(ctx context.Context, query string, keyValuePairs ...any) (bool, error)
+	Upsert(table string, conflictColumns []string, values map[string]any, updateColumns []string) Exec
+	SoftDelete(table string, keyValuePairs ...any) Exec
+	Transact(ctx context.Context, fn func(tx Tx) error) error
+	ReadTx(ctx context.Context, fn func(tx ReadTx) error) error
+	Batch(ctx context.Context, query string, args map[string]any) ([]BatchResult, error)
+}
+
+// supportsBatch reports whether pipelines can be sent as a single driver batch.
+func (postgresInstance *postgres) supportsBatch() bool {
+	return batchSupported && postgresInstance.driverName == driverPgx
 }
 
 // Select is a query that selects data from the database.
@@ -47,5 +99,214 @@ func (postgresInstance *postgres) Delete(query string, keyValuePairs ...any) Exe
 
 // FromResult is a query that returns the result of a query.
 func (postgresInstance *postgres) FromResult(from string) string {
-	return fmt.Sprintf("%s%s", qResult, from)
+	return fmt.Sprintf("%s%s", postgresInstance.resultHook, from)
+}
+
+// Ping verifies the database connection is still alive, on the primary and every read replica.
+func (postgresInstance *postgres) Ping(ctx context.Context) error {
+	ctx, cancel := postgresInstance.withDefaultTimeout(ctx)
+	defer cancel()
+
+	if err := postgresInstance.database.PingContext(ctx); err != nil {
+		return postgresInstance.wrapError(errors.WithStack(err))
+	}
+	for _, replica := range postgresInstance.replicas {
+		if err := replica.PingContext(ctx); err != nil {
+			return postgresInstance.wrapError(errors.WithStack(err))
+		}
+	}
+	return nil
+}
+
+// DB returns the underlying primary pool for features this client does not expose,
+// such as LISTEN/NOTIFY or custom mappers. Queries run on it directly bypass the client's
+// debug output, query logger and error policy.
+func (postgresInstance *postgres) DB() *sqlx.DB {
+	return postgresInstance.database
+}
+
+// Stats returns the connection pool statistics of the primary.
+func (postgresInstance *postgres) Stats() sql.DBStats {
+	return postgresInstance.database.Stats()
+}
+
+// Scalar selects a single value into destination.
+// It returns an error matching both ErrNotFound and sql.ErrNoRows when no row is found.
+func (postgresInstance *postgres) Scalar(ctx context.Context, query string, destination any, keyValuePairs ...any) error {
+	found, err := postgresInstance.Select(query, destination, keyValuePairs...).One(ctx)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return postgresInstance.wrapError(errors.WithStack(errNoRows))
+	}
+	return nil
+}
+
+// Count runs a count query, e.g. SELECT count(*) FROM users WHERE active = :active,
+// and returns its result. It returns an error if the query does not return exactly
+// one row with one column.
+func (postgresInstance *postgres) Count(ctx context.Context, query string, keyValuePairs ...any) (int64, error) {
+	var count int64
+	err := postgresInstance.singleValue(ctx, "postgres.Count", query, &count, keyValuePairs)
+	return count, err
+}
+
+// Exists returns true if query returns at least one row. query is wrapped in SELECT EXISTS(...),
+// so it can select anything, e.g. SELECT 1 FROM users WHERE email = :email.
+func (postgresInstance *postgres) Exists(ctx context.Context, query string, keyValuePairs ...any) (bool, error) {
+	var exists bool
+	// The newline keeps a trailing line comment from swallowing the closing parenthesis
+	query = "SELECT EXISTS(" + strings.TrimRight(strings.TrimSpace(query), ";") + "\n)"
+	err := postgresInstance.singleValue(ctx, "postgres.Exists", query, &exists, keyValuePairs)
+	return exists, err
+}
+
+// singleValue runs a read query that must return exactly one row with one column
+// and scans that value into destination.
+func (postgresInstance *postgres) singleValue(ctx context.Context, name, query string, destination any, keyValuePairs []any) (err error) {
+	ctx, cancel := postgresInstance.withDefaultTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = postgresInstance.wrapError(err)
+	}()
+
+	ctx, endSpan := postgresInstance.startSpan(ctx, name, query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	arguments, err := pairsWithMapper(keyValuePairs, postgresInstance.fieldMapper)
+	if err != nil {
+		return err
+	}
+	if err = checkArguments(query, arguments); err != nil {
+		return err
+	}
+	statement, arguments := expandInClauses(query, arguments)
+
+	postgresInstance.beforeQuery(ctx, false, statement, arguments)
+	defer func(started time.Time) {
+		rowsAffected := int64(1)
+		if err != nil {
+			rowsAffected = 0
+		}
+		postgresInstance.afterQuery(ctx, statement, arguments, started, rowsAffected, err)
+	}(time.Now())
+
+	preparedStatement, release, err := postgresInstance.prepareNamed(ctx, postgresInstance.reader(false), statement)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = release(err)
+	}()
+
+	rows, err := preparedStatement.QueryxContext(ctx, arguments)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(columns) != 1 {
+		return errors.Errorf("query must return exactly one column, got %d", len(columns))
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.New("query must return exactly one row, got none")
+	}
+	if err = rows.Scan(destination); err != nil {
+		return errors.WithStack(err)
+	}
+	if rows.Next() {
+		return errors.New("query must return exactly one row, got more")
+	}
+	return errors.WithStack(rows.Err())
+}
+
+// UpdateReturning runs an UPDATE ... RETURNING query on the primary, scans every returned row
+// into destination, which must be a pointer to a slice, and returns the number of rows returned.
+//
+// Postgres counts a matched row as updated even when the new values equal the old ones, so
+// zero means no row matched. To skip unchanged rows, filter them out in the WHERE clause:
+//
+//	db.UpdateReturning(ctx, "UPDATE users SET name = :name WHERE id = :id AND name IS DISTINCT FROM :name RETURNING id", &ids, "id", 1, "name", "Bob")
+func (postgresInstance *postgres) UpdateReturning(ctx context.Context, query string, destination any, keyValuePairs ...any) (count int64, err error) {
+	ctx, cancel := postgresInstance.withDefaultTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = postgresInstance.wrapError(err)
+	}()
+
+	ctx, endSpan := postgresInstance.startSpan(ctx, "postgres.UpdateReturning", query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	arguments, err := pairsWithMapper(keyValuePairs, postgresInstance.fieldMapper)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkArguments(query, arguments); err != nil {
+		return 0, err
+	}
+	statement, arguments := expandInClauses(query, arguments)
+
+	postgresInstance.beforeQuery(ctx, false, statement, arguments)
+	defer func(started time.Time) {
+		postgresInstance.afterQuery(ctx, statement, arguments, started, count, err)
+	}(time.Now())
+
+	preparedStatement, release, err := postgresInstance.prepareNamed(ctx, postgresInstance.database, statement)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = release(err)
+	}()
+
+	if err = preparedStatement.SelectContext(ctx, destination, arguments); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return returnedRows(destination), nil
+}
+
+// withDefaultTimeout returns ctx bounded by the WithDefaultTimeout timeout if ctx has no
+// deadline, and ctx unchanged otherwise. The returned cancel func must be called once the
+// operation is done.
+func (postgresInstance *postgres) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if postgresInstance.defaultTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, hasDeadline := ctx.Deadline(); hasDeadline {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, postgresInstance.defaultTimeout)
+}
+
+// Close closes the connection pool. It is safe to call more than once;
+// every call returns the result of the first one.
+// Queries on a closed client fail with sql: database is closed.
+func (postgresInstance *postgres) Close() error {
+	postgresInstance.closeOnce.Do(func() {
+		if postgresInstance.statements != nil {
+			postgresInstance.statements.close()
+		}
+		err := postgresInstance.database.Close()
+		for _, replica := range postgresInstance.replicas {
+			if replicaErr := replica.Close(); err == nil {
+				err = replicaErr
+			}
+		}
+		postgresInstance.closeErr = postgresInstance.wrapError(errors.WithStack(err))
+	})
+	return postgresInstance.closeErr
 }
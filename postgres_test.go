@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deadlineRecorder is a fake handler recording the deadline of the context each query runs with.
+type deadlineRecorder struct {
+	mu        sync.Mutex
+	deadlines []time.Time // Zero for a context without a deadline
+}
+
+func (r *deadlineRecorder) handle(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	deadline, _ := ctx.Deadline()
+	r.mu.Lock()
+	r.deadlines = append(r.deadlines, deadline)
+	r.mu.Unlock()
+	return selectOne(ctx, query, args)
+}
+
+func (r *deadlineRecorder) last() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deadlines[len(r.deadlines)-1]
+}
+
+func TestDefaultTimeoutWithoutDeadline(t *testing.T) {
+	recorder := &deadlineRecorder{}
+	db := newFakeDriver(recorder.handle).client(t, WithDefaultTimeout(time.Minute))
+
+	started := time.Now()
+	var id int64
+	if _, err := db.Select("SELECT id FROM t", &id).One(context.Background()); err != nil {
+		t.Fatalf("One: %v", err)
+	}
+	finished := time.Now()
+	deadline := recorder.last()
+	if deadline.IsZero() {
+		t.Fatal("query without a deadline ran without the default timeout")
+	}
+	if deadline.Before(started.Add(time.Minute)) || deadline.After(finished.Add(time.Minute)) {
+		t.Errorf("query deadline is %v after the call, want the one minute default", deadline.Sub(started))
+	}
+}
+
+func TestDefaultTimeoutKeepsExistingDeadline(t *testing.T) {
+	recorder := &deadlineRecorder{}
+	db := newFakeDriver(recorder.handle).client(t, WithDefaultTimeout(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	want, _ := ctx.Deadline()
+
+	var id int64
+	if _, err := db.Select("SELECT id FROM t", &id).One(ctx); err != nil {
+		t.Fatalf("One: %v", err)
+	}
+	if _, err := db.Insert("INSERT INTO t (a) VALUES (:a) RETURNING id", "a", 1).ExecInsert(ctx); err != nil {
+		t.Fatalf("ExecInsert: %v", err)
+	}
+	for i, deadline := range recorder.deadlines {
+		if !deadline.Equal(want) {
+			t.Errorf("query %d ran with deadline %v, want the caller's %v", i, deadline, want)
+		}
+	}
+}
+
+func TestDefaultTimeoutBoundsWholeTransaction(t *testing.T) {
+	db := newFakeDriver(nil).client(t, WithDefaultTimeout(50*time.Millisecond))
+
+	err := db.Transact(context.Background(), func(tx Tx) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	if err == nil {
+		t.Error("Transact outliving the default timeout committed, want an error")
+	}
+}
+
+func TestDefaultTimeoutBoundsRowsIteration(t *testing.T) {
+	recorder := &deadlineRecorder{}
+	db := newFakeDriver(recorder.handle).client(t, WithDefaultTimeout(time.Minute))
+
+	var id int64
+	rows, err := db.Select("SELECT id FROM t", &id).Rows(context.Background())
+	if err != nil {
+		t.Fatalf("Rows: %v", err)
+	}
+	defer rows.Close()
+	if recorder.last().IsZero() {
+		t.Error("Rows ran without the default timeout")
+	}
+	for rows.Next() {
+		if err = rows.Scan(&id); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		t.Errorf("iteration within the timeout failed: %v", err)
+	}
+}
+
+// activeUsers answers a count of the users whose active column matches the first argument,
+// in a table of active users only.
+func activeUsers(active int64) fakeHandler {
+	return func(_ context.Context, _ string, args []driver.NamedValue) (fakeResult, error) {
+		count := int64(0)
+		if args[0].Value == true {
+			count = active
+		}
+		return fakeResult{columns: []string{"count"}, rows: [][]driver.Value{{count}}}, nil
+	}
+}
+
+func TestCount(t *testing.T) {
+	ctx := context.Background()
+	const query = "SELECT count(*) FROM users WHERE active = :active"
+
+	db := newFakeDriver(activeUsers(3)).client(t)
+	for active, want := range map[bool]int64{true: 3, false: 0} {
+		if count, err := db.Count(ctx, query, "active", active); err != nil || count != want {
+			t.Errorf("Count(active %v) = %d, %v, want %d", active, count, err, want)
+		}
+	}
+
+	empty := newFakeDriver(activeUsers(0)).client(t)
+	if count, err := empty.Count(ctx, query, "active", true); err != nil || count != 0 {
+		t.Errorf("Count on an empty table = %d, %v, want 0", count, err)
+	}
+}
+
+func TestCountRejectsOtherShapes(t *testing.T) {
+	results := map[string]fakeResult{
+		"no row":      {columns: []string{"count"}},
+		"two rows":    {columns: []string{"count"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}},
+		"two columns": {columns: []string{"count", "sum"}, rows: [][]driver.Value{{int64(1), int64(2)}}},
+	}
+	for name, result := range results {
+		db := newFakeDriver(func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+			return result, nil
+		}).client(t)
+		if count, err := db.Count(context.Background(), "SELECT count(*) FROM users"); err == nil {
+			t.Errorf("Count with %s = %d, want an error", name, count)
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	var queries []string
+	db := newFakeDriver(func(_ context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+		queries = append(queries, query)
+		return fakeResult{columns: []string{"exists"}, rows: [][]driver.Value{{args[0].Value == "alice@example.com"}}}, nil
+	}).client(t, WithMaxOpenConns(1))
+
+	for email, want := range map[string]bool{"alice@example.com": true, "nobody@example.com": false} {
+		exists, err := db.Exists(context.Background(), "SELECT 1 FROM users WHERE email = :email -- by email", "email", email)
+		if err != nil || exists != want {
+			t.Errorf("Exists(%s) = %v, %v, want %v", email, exists, err, want)
+		}
+	}
+
+	if want := "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 -- by email\n)"; queries[0] != want {
+		t.Errorf("query = %q, want %q", queries[0], want)
+	}
+}
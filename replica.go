@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// reader returns the pool a read query runs on: the next read replica in round-robin
+// order, or the primary if no replica is configured or primary is true.
+func (postgresInstance *postgres) reader(primary bool) *sqlx.DB {
+	if primary || len(postgresInstance.replicas) == 0 {
+		return postgresInstance.database
+	}
+	index := postgresInstance.nextReplica.Add(1) - 1
+	return postgresInstance.replicas[index%uint64(len(postgresInstance.replicas))]
+}
+
+// connectReplicas opens a pool for every read replica dsn with the same driver and pool settings
+// as the primary. Already opened pools are closed if one of them fails to connect.
+func connectReplicas(ctx context.Context, cfg *config) ([]*sqlx.DB, error) {
+	replicas := make([]*sqlx.DB, 0, len(cfg.readReplicaDsns))
+	for _, dsn := range cfg.readReplicaDsns {
+		replicaCfg := *cfg
+		replicaCfg.dsn = dsn
+
+		replica, err := connect(ctx, &replicaCfg)
+		if err != nil {
+			for _, opened := range replicas {
+				_ = opened.Close()
+			}
+			return nil, errors.WithStack(err)
+		}
+		configurePool(replica, cfg)
+		replicas = append(replicas, replica)
+	}
+	return replicas, nil
+}
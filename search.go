@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type (
+	// TSQuery is a sanitized tsquery expression safe to pass to to_tsquery.
+	TSQuery string
+
+	// FullTextSearchClause holds the SQL fragments for a full-text search.
+	// All fragments reference the same named parameter, bound through Kv. The parameter is
+	// named after the column, e.g. __fts_p_search for p.search, so searches on different
+	// columns can be combined in one query.
+	FullTextSearchClause struct {
+		// Where is the match predicate, e.g. search @@ plainto_tsquery(:__fts_search).
+		Where string
+
+		// Rank is the ts_rank expression, usable in a select list or ORDER BY.
+		Rank string
+
+		// OrderBy is " ORDER BY <Rank> DESC", ready to append to a query.
+		OrderBy string
+
+		// Kv contains the key-value pair for the search parameter.
+		Kv []any
+	}
+)
+
+// NewTSQuery sanitizes raw user input into a tsquery that matches every word.
+// Anything other than letters and digits is treated as a separator, so operators,
+// quotes and parentheses in the input can never produce a tsquery syntax error.
+func NewTSQuery(input string) TSQuery {
+	terms := strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return TSQuery(strings.Join(terms, " & "))
+}
+
+// String returns the tsquery expression.
+func (q TSQuery) String() string {
+	return string(q)
+}
+
+// Value returns the tsquery expression for use as a query parameter.
+func (q TSQuery) Value() (driver.Value, error) {
+	return string(q), nil
+}
+
+// FullTextSearch builds a full-text search predicate and ranking for a tsvector column.
+// The query is bound through plainto_tsquery, so user input is never interpolated into SQL.
+// Two searches on the same column bind the same key, so passing both Kv to one query fails.
+//
+// Example:
+//
+//	fts := postgres.FullTextSearch("p.search", input)
+//	query := "SELECT * FROM posts p WHERE " + fts.Where + fts.OrderBy
+//	db.Select(query, &posts, fts.Kv...).Many(ctx)
+func FullTextSearch(column, query string) FullTextSearchClause {
+	key := generatedKeyPrefix + "fts_" + strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && isWordCharacter(byte(r)) {
+			return r
+		}
+		return '_'
+	}, column)
+	column = quoteIdentifier(column)
+	tsQuery := fmt.Sprintf("plainto_tsquery(:%s)", key)
+	rank := fmt.Sprintf("ts_rank(%s, %s)", column, tsQuery)
+
+	return FullTextSearchClause{
+		Where:   fmt.Sprintf("%s @@ %s", column, tsQuery),
+		Rank:    rank,
+		OrderBy: " ORDER BY " + rank + " DESC",
+		Kv:      []any{key, query},
+	}
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFullTextSearchKeys(t *testing.T) {
+	title := FullTextSearch("p.title", "go")
+	body := FullTextSearch("p.body", "postgres")
+	if title.Where != `"p"."title" @@ plainto_tsquery(:__fts_p_title)` {
+		t.Errorf("Where = %q", title.Where)
+	}
+
+	arguments, err := Pairs(append(append([]any{"fts_query", "caller"}, title.Kv...), body.Kv...))
+	if err != nil {
+		t.Fatalf("Pairs: %v", err)
+	}
+	want := map[string]any{"fts_query": "caller", "__fts_p_title": "go", "__fts_p_body": "postgres"}
+	for key, value := range want {
+		if arguments[key] != value {
+			t.Errorf("%s = %v, want %v", key, arguments[key], value)
+		}
+	}
+
+	again := FullTextSearch("p.title", "sql")
+	if _, err = Pairs(append(title.Kv, again.Kv...)); err == nil || !strings.Contains(err.Error(), "__fts_p_title") {
+		t.Errorf("Pairs() with two searches on one column = %v, want a generated key error", err)
+	}
+}
+
+func TestNewTSQuery(t *testing.T) {
+	if got := NewTSQuery(`go & (postgres | "sql")!`); got != "go & postgres & sql" {
+		t.Errorf("NewTSQuery() = %q", got)
+	}
+}
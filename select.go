@@ -3,6 +3,10 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 )
 
 // selectQuery is a query that selects data from the database.
@@ -13,6 +17,10 @@ type selectQuery struct {
 	destination   any
 	arguments     map[string]any
 	debug         bool
+	primary       bool
+	lastDuration  time.Duration
+
+	statementTimeout time.Duration
 }
 
 // Select is an interface for selecting data from the database.
@@ -20,6 +28,11 @@ type Select interface {
 	Debug() Select
 	One(ctx context.Context) (found bool, err error)
 	Many(ctx context.Context) (found bool, err error)
+	Rows(ctx context.Context) (*Iterator, error)
+	LastDuration() time.Duration
+	Primary() Select
+	WithStatementTimeout(timeout time.Duration) Select
+	DryRun() (DryRunQuery, error)
 }
 
 // Select is a query that selects data from the database.
@@ -28,65 +41,208 @@ func (query *selectQuery) Debug() Select {
 	return query
 }
 
+// Primary makes the query read from the primary even when read replicas are configured,
+// e.g. to read a row that was just written.
+func (query *selectQuery) Primary() Select {
+	query.primary = true
+	return query
+}
+
+// WithStatementTimeout makes the server cancel the query if it runs longer than timeout,
+// like SET LOCAL statement_timeout, regardless of the deadline of ctx. The query then runs
+// in its own transaction, and a cancelled query returns an error matching ErrStatementTimeout.
+func (query *selectQuery) WithStatementTimeout(timeout time.Duration) Select {
+	query.statementTimeout = timeout
+	return query
+}
+
+// LastDuration returns how long the last execution of the query took.
+func (query *selectQuery) LastDuration() time.Duration {
+	return query.lastDuration
+}
+
 // One selects a single row from the database.
+// If the connection turns out to be unusable, e.g. after a failover, the query is retried
+// once on a fresh connection.
 func (query *selectQuery) One(ctx context.Context) (found bool, err error) {
+	ctx, cancel := query.postgres.withDefaultTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = query.postgres.wrapError(query.timeoutError(ctx, err))
+	}()
+
+	ctx, endSpan := query.postgres.startSpan(ctx, "postgres.One", query.query)
+	defer func() {
+		endSpan(err)
+	}()
+
 	// Convert keyValuePairs to arguments map
 	if query.arguments == nil {
-		query.arguments, err = Pairs(query.keyValuePairs)
+		query.arguments, err = pairsWithMapper(query.keyValuePairs, query.postgres.fieldMapper)
 		if err != nil {
 			return false, err
 		}
 	}
 
-	// Debug query if either global debug or instance debug is enabled
-	if query.debug {
-		debugQuery(query.query, query.arguments)
-	}
-
-	preparedStatement, err := query.postgres.database.PrepareNamedContext(ctx, query.query)
-	if err != nil {
+	if err = checkArguments(query.query, query.arguments); err != nil {
 		return false, err
 	}
-	defer preparedStatement.Close()
+	statement, arguments := expandInClauses(query.query, query.arguments)
+
+	// Debug query if either global debug or instance debug is enabled
+	query.postgres.beforeQuery(ctx, query.debug, statement, arguments)
+	defer func(started time.Time) {
+		query.lastDuration = query.postgres.afterQuery(ctx, statement, arguments, started, selectedRows(found, query.destination), err)
+	}(time.Now())
 
-	err = preparedStatement.GetContext(ctx, query.destination, query.arguments)
+	err = retryBadConn(ctx, func() (err error) {
+		preparedStatement, release, err := query.prepare(ctx, statement)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = release(err)
+		}()
+		return preparedStatement.GetContext(ctx, query.destination, arguments)
+	})
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
 		}
-		return false, err
+		return false, errors.WithStack(err)
 	}
 	return true, nil
 }
 
 // Many selects multiple rows from the database.
+// If the connection turns out to be unusable, the query is retried once on a fresh connection.
 func (query *selectQuery) Many(ctx context.Context) (found bool, err error) {
+	ctx, cancel := query.postgres.withDefaultTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = query.postgres.wrapError(query.timeoutError(ctx, err))
+	}()
+
+	ctx, endSpan := query.postgres.startSpan(ctx, "postgres.Many", query.query)
+	defer func() {
+		endSpan(err)
+	}()
+
 	// Convert keyValuePairs to arguments map
 	if query.arguments == nil {
-		query.arguments, err = Pairs(query.keyValuePairs)
+		query.arguments, err = pairsWithMapper(query.keyValuePairs, query.postgres.fieldMapper)
 		if err != nil {
 			return false, err
 		}
 	}
 
-	// Debug query if either global debug or instance debug is enabled
-	if query.debug {
-		debugQuery(query.query, query.arguments)
-	}
-
-	preparedStatement, err := query.postgres.database.PrepareNamedContext(ctx, query.query)
-	if err != nil {
+	if err = checkArguments(query.query, query.arguments); err != nil {
 		return false, err
 	}
-	defer preparedStatement.Close()
+	statement, arguments := expandInClauses(query.query, query.arguments)
+
+	// Debug query if either global debug or instance debug is enabled
+	query.postgres.beforeQuery(ctx, query.debug, statement, arguments)
+	defer func(started time.Time) {
+		query.lastDuration = query.postgres.afterQuery(ctx, statement, arguments, started, selectedRows(found, query.destination), err)
+	}(time.Now())
 
-	err = preparedStatement.SelectContext(ctx, query.destination, query.arguments) // Use SelectContext for slice results
+	err = retryBadConn(ctx, func() (err error) {
+		preparedStatement, release, err := query.prepare(ctx, statement)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = release(err)
+		}()
+		truncateSlice(query.destination)
+		return preparedStatement.SelectContext(ctx, query.destination, arguments)
+	})
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
 		}
-		return false, err
+		return false, errors.WithStack(err)
 	}
 
 	return true, nil
 }
+
+// Rows selects rows from the database and returns an iterator over them.
+// The destination passed to Select is not used; call Scan on the iterator instead.
+// The iterator must be closed, unless it is iterated until Next returns false.
+func (query *selectQuery) Rows(ctx context.Context) (iterator *Iterator, err error) {
+	// The iterator outlives this call, so it cancels the default timeout when it is closed
+	ctx, cancel := query.postgres.withDefaultTimeout(ctx)
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+
+	defer func() {
+		err = query.postgres.wrapError(query.timeoutError(ctx, err))
+	}()
+
+	ctx, endSpan := query.postgres.startSpan(ctx, "postgres.Rows", query.query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	// Convert keyValuePairs to arguments map
+	if query.arguments == nil {
+		query.arguments, err = pairsWithMapper(query.keyValuePairs, query.postgres.fieldMapper)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = checkArguments(query.query, query.arguments); err != nil {
+		return nil, err
+	}
+	statement, arguments := expandInClauses(query.query, query.arguments)
+
+	// Debug query if either global debug or instance debug is enabled
+	query.postgres.beforeQuery(ctx, query.debug, statement, arguments)
+	defer func(started time.Time) {
+		query.lastDuration = query.postgres.afterQuery(ctx, statement, arguments, started, unknownRowsAffected, err)
+	}(time.Now())
+
+	preparedStatement, release, err := query.prepare(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := preparedStatement.QueryxContext(ctx, arguments)
+	if err != nil {
+		_ = release(err)
+		return nil, errors.WithStack(err)
+	}
+
+	return &Iterator{
+		postgres:         query.postgres,
+		cancel:           cancel,
+		releaseStatement: release,
+		rows:             rows,
+	}, nil
+}
+
+// prepare prepares statement on the pool the query reads from, or in its own transaction
+// when WithStatementTimeout is set.
+func (query *selectQuery) prepare(ctx context.Context, statement string) (*sqlx.NamedStmt, func(err error) error, error) {
+	database := query.postgres.reader(query.primary)
+	if query.statementTimeout > 0 {
+		return query.postgres.prepareNamedWithTimeout(ctx, database, statement, query.statementTimeout, query.debug)
+	}
+	return query.postgres.prepareNamed(ctx, database, statement)
+}
+
+// timeoutError marks err as ErrStatementTimeout when the query runs with WithStatementTimeout.
+func (query *selectQuery) timeoutError(ctx context.Context, err error) error {
+	if query.statementTimeout <= 0 {
+		return err
+	}
+	return markStatementTimeout(ctx, err)
+}
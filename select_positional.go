@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var positionalParameterRegex = regexp.MustCompile(`\$[0-9]+`)
+
+// positionalSelectQuery is a query that selects data from the database using $N parameters.
+type positionalSelectQuery struct {
+	postgres     *postgres
+	query        string
+	arguments    []any
+	destination  any
+	debug        bool
+	primary      bool
+	lastDuration time.Duration
+
+	statementTimeout time.Duration
+}
+
+// SelectPositional is a query that selects data from the database using positional
+// $1, $2, ... parameters instead of named :key parameters.
+// arguments are bound in order; the key-value pair semantics of Select do not apply.
+func (postgresInstance *postgres) SelectPositional(query string, destination any, arguments ...any) Select {
+	return &positionalSelectQuery{
+		postgres:    postgresInstance,
+		query:       query,
+		arguments:   arguments,
+		destination: destination,
+	}
+}
+
+// Debug enables debug output for the query.
+func (query *positionalSelectQuery) Debug() Select {
+	query.debug = true
+	return query
+}
+
+// Primary makes the query read from the primary even when read replicas are configured.
+func (query *positionalSelectQuery) Primary() Select {
+	query.primary = true
+	return query
+}
+
+// WithStatementTimeout makes the server cancel the query if it runs longer than timeout.
+func (query *positionalSelectQuery) WithStatementTimeout(timeout time.Duration) Select {
+	query.statementTimeout = timeout
+	return query
+}
+
+// LastDuration returns how long the last execution of the query took.
+func (query *positionalSelectQuery) LastDuration() time.Duration {
+	return query.lastDuration
+}
+
+// One selects a single row from the database, retrying once on a fresh connection if
+// the connection turns out to be unusable.
+func (query *positionalSelectQuery) One(ctx context.Context) (found bool, err error) {
+	ctx, cancel := query.postgres.withDefaultTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = query.postgres.wrapError(query.timeoutError(ctx, err))
+	}()
+
+	ctx, endSpan := query.postgres.startSpan(ctx, "postgres.One", query.query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	query.postgres.beforePositionalQuery(ctx, query.debug, query.query, query.arguments)
+	defer func(started time.Time) {
+		query.lastDuration = query.postgres.afterQuery(ctx, query.query, positionalArguments(query.arguments), started, selectedRows(found, query.destination), err)
+	}(time.Now())
+
+	database := query.postgres.reader(query.primary)
+	err = retryBadConn(ctx, func() error {
+		if query.statementTimeout > 0 {
+			return query.postgres.withStatementTimeout(ctx, database, query.statementTimeout, query.debug, func(transaction *sqlx.Tx) error {
+				return transaction.GetContext(ctx, query.destination, query.query, query.arguments...)
+			})
+		}
+		return database.GetContext(ctx, query.destination, query.query, query.arguments...)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// Many selects multiple rows from the database, retrying once on a fresh connection if
+// the connection turns out to be unusable.
+func (query *positionalSelectQuery) Many(ctx context.Context) (found bool, err error) {
+	ctx, cancel := query.postgres.withDefaultTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = query.postgres.wrapError(query.timeoutError(ctx, err))
+	}()
+
+	ctx, endSpan := query.postgres.startSpan(ctx, "postgres.Many", query.query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	query.postgres.beforePositionalQuery(ctx, query.debug, query.query, query.arguments)
+	defer func(started time.Time) {
+		query.lastDuration = query.postgres.afterQuery(ctx, query.query, positionalArguments(query.arguments), started, selectedRows(found, query.destination), err)
+	}(time.Now())
+
+	database := query.postgres.reader(query.primary)
+	err = retryBadConn(ctx, func() error {
+		truncateSlice(query.destination)
+		if query.statementTimeout > 0 {
+			return query.postgres.withStatementTimeout(ctx, database, query.statementTimeout, query.debug, func(transaction *sqlx.Tx) error {
+				return transaction.SelectContext(ctx, query.destination, query.query, query.arguments...)
+			})
+		}
+		return database.SelectContext(ctx, query.destination, query.query, query.arguments...)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// Rows selects rows from the database and returns an iterator over them.
+func (query *positionalSelectQuery) Rows(ctx context.Context) (iterator *Iterator, err error) {
+	// The iterator outlives this call, so it cancels the default timeout when it is closed
+	ctx, cancel := query.postgres.withDefaultTimeout(ctx)
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+
+	defer func() {
+		err = query.postgres.wrapError(query.timeoutError(ctx, err))
+	}()
+
+	ctx, endSpan := query.postgres.startSpan(ctx, "postgres.Rows", query.query)
+	defer func() {
+		endSpan(err)
+	}()
+
+	query.postgres.beforePositionalQuery(ctx, query.debug, query.query, query.arguments)
+	defer func(started time.Time) {
+		query.lastDuration = query.postgres.afterQuery(ctx, query.query, positionalArguments(query.arguments), started, unknownRowsAffected, err)
+	}(time.Now())
+
+	database := query.postgres.reader(query.primary)
+	if query.statementTimeout <= 0 {
+		rows, err := database.QueryxContext(ctx, query.query, query.arguments...)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &Iterator{
+			postgres: query.postgres,
+			cancel:   cancel,
+			rows:     rows,
+		}, nil
+	}
+
+	transaction, end, err := query.postgres.beginWithTimeout(ctx, database, query.statementTimeout, query.debug)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := transaction.QueryxContext(ctx, query.query, query.arguments...)
+	if err != nil {
+		_ = end(err)
+		return nil, errors.WithStack(err)
+	}
+
+	return &Iterator{
+		postgres:         query.postgres,
+		cancel:           cancel,
+		releaseStatement: end,
+		rows:             rows,
+	}, nil
+}
+
+// timeoutError marks err as ErrStatementTimeout when the query runs with WithStatementTimeout.
+func (query *positionalSelectQuery) timeoutError(ctx context.Context, err error) error {
+	if query.statementTimeout <= 0 {
+		return err
+	}
+	return markStatementTimeout(ctx, err)
+}
+
+// debugPositionalQuery prints query with every $N placeholder replaced by its argument,
+// keyed by placeholder as positionalArguments does.
+func debugPositionalQuery(query string, arguments map[string]any, fields map[string]any) {
+	// Replace every whole $N in one pass, so $1 does not clobber $10 and values are never re-scanned
+	finalQuery := positionalParameterRegex.ReplaceAllStringFunc(query, func(placeholder string) string {
+		value, exists := arguments[placeholder]
+		if !exists {
+			return placeholder
+		}
+		return debugValue(value)
+	})
+
+	fmt.Println("[DEBUG SQL]", formatFields(fields)+finalQuery)
+}
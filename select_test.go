@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// brokenOnce answers the first query with two rows and a broken connection, and every later
+// query with three rows, counting the queries in calls.
+func brokenOnce(calls *atomic.Int64) fakeHandler {
+	return func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+		result := fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}}
+		if calls.Add(1) == 1 {
+			result.rowsErr = driver.ErrBadConn
+			return result, nil
+		}
+		result.rows = append(result.rows, []driver.Value{int64(3)})
+		return result, nil
+	}
+}
+
+func TestManyRetriesBadConn(t *testing.T) {
+	tests := []struct {
+		name  string
+		query func(db *postgres, destination *[]int64) Select
+	}{
+		{"named", func(db *postgres, destination *[]int64) Select {
+			return db.Select("SELECT id FROM users WHERE active = :active", destination, "active", true)
+		}},
+		{"positional", func(db *postgres, destination *[]int64) Select {
+			return db.SelectPositional("SELECT id FROM users WHERE active = $1", destination, true)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls atomic.Int64
+			db := newFakeDriver(brokenOnce(&calls)).client(t)
+
+			var ids []int64
+			found, err := test.query(db, &ids).Many(context.Background())
+			if err != nil || !found {
+				t.Fatalf("Many() = %v, %v, want found", found, err)
+			}
+			if got := calls.Load(); got != 2 {
+				t.Errorf("query ran %d times, want 2", got)
+			}
+			// The rows of the broken attempt must not be kept
+			if len(ids) != 3 {
+				t.Errorf("ids = %v, want [1 2 3]", ids)
+			}
+		})
+	}
+}
+
+func TestOneRetriesBadConn(t *testing.T) {
+	var calls atomic.Int64
+	db := newFakeDriver(func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+		if calls.Add(1) == 1 {
+			return fakeResult{columns: []string{"id"}, rowsErr: driver.ErrBadConn}, nil
+		}
+		return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(7)}}}, nil
+	}).client(t)
+
+	var id int64
+	found, err := db.Select("SELECT id FROM users WHERE email = :email", &id, "email", "a@example.com").One(context.Background())
+	if err != nil || !found || id != 7 {
+		t.Fatalf("One() = %v, %v, id %d, want found id 7", found, err, id)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("query ran %d times, want 2", got)
+	}
+}
+
+// captureStdout returns what run prints to stdout.
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+	run()
+	_ = writer.Close()
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	return string(output)
+}
+
+func TestDebugOutputRedactsArguments(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  []string
+		query func(db *postgres, destination *int64) Select
+		want  string
+	}{
+		{"named", []string{"password"}, func(db *postgres, destination *int64) Select {
+			return db.Select("SELECT id FROM users WHERE name = :name AND password = :password", destination, "name", "alice", "password", "secret")
+		}, "name = 'alice' AND password = '***'"},
+		{"positional", []string{"$2"}, func(db *postgres, destination *int64) Select {
+			return db.SelectPositional("SELECT id FROM users WHERE name = $1 AND password = $2", destination, "alice", "secret")
+		}, "name = 'alice' AND password = '***'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newFakeDriver(selectOne).client(t, WithRedactedKeys(test.keys...))
+			var id int64
+			output := captureStdout(t, func() {
+				if _, err := test.query(db, &id).Debug().One(context.Background()); err != nil {
+					t.Errorf("One: %v", err)
+				}
+			})
+			if !strings.Contains(output, test.want) || strings.Contains(output, "secret") {
+				t.Errorf("debug output = %q, want it to contain %q and not the secret", output, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	softDeleteColumn = "deleted_at"
+)
+
+// SoftDelete builds an UPDATE that sets deleted_at to now() on the rows of table matching
+// every column = value pair in keyValuePairs, instead of deleting them. Rows that are already
+// soft-deleted keep their original deleted_at. ExecUpdate returns the number of rows soft-deleted.
+//
+// At least one pair is required, so a whole table is never soft-deleted by accident.
+//
+// Example:
+//
+//	db.SoftDelete("users", "id", 1).ExecUpdate(ctx)
+func (postgresInstance *postgres) SoftDelete(table string, keyValuePairs ...any) Exec {
+	query, whereKeyValuePairs, err := buildSoftDelete(table, keyValuePairs)
+
+	exec := newExecQuery(postgresInstance, query, whereKeyValuePairs).(*execQuery)
+	exec.err = err
+	return exec
+}
+
+// buildSoftDelete builds the soft-delete UPDATE statement and its key-value pairs.
+func buildSoftDelete(table string, keyValuePairs []any) (string, []any, error) {
+	if table == "" {
+		return "", nil, errors.New("soft delete requires a table name")
+	}
+	if len(keyValuePairs) == 0 {
+		return "", nil, errors.New("soft delete requires at least one column = value predicate")
+	}
+	if len(keyValuePairs)%2 == 1 {
+		return "", nil, errors.Errorf("invalid key-value pairs: expected even number of arguments but got %d", len(keyValuePairs))
+	}
+
+	where := NewWhere()
+	for i := 0; i < len(keyValuePairs); i += 2 {
+		where.Eq(fmt.Sprintf("%v", keyValuePairs[i]), keyValuePairs[i+1])
+	}
+	clause, _ := where.Build()
+
+	column := quoteIdentifier(softDeleteColumn)
+	query := fmt.Sprintf("UPDATE %s SET %s = now() WHERE %s AND %s IS NULL", quoteIdentifier(table), column, clause, column)
+	return query, where.Kv(), nil
+}
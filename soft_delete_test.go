@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+// queryRecorder answers every exec with rowsAffected and records the queries and their arguments.
+type queryRecorder struct {
+	rowsAffected int64
+	queries      []string
+	args         [][]any
+}
+
+func (r *queryRecorder) handle(_ context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	r.queries = append(r.queries, query)
+	r.args = append(r.args, values)
+	return fakeResult{rowsAffected: r.rowsAffected}, nil
+}
+
+func TestSoftDeleteSetsDeletedAt(t *testing.T) {
+	recorder := &queryRecorder{rowsAffected: 2}
+	db := newFakeDriver(recorder.handle).client(t, WithMaxOpenConns(1))
+
+	rows, err := db.SoftDelete("users", "tenant_id", int64(7), "role", "guest").ExecUpdate(context.Background())
+	if err != nil || rows != 2 {
+		t.Fatalf("ExecUpdate = %d, %v, want 2 rows", rows, err)
+	}
+	if want := `UPDATE "users" SET "deleted_at" = now() WHERE "tenant_id" = $1 AND "role" = $2 AND "deleted_at" IS NULL`; recorder.queries[0] != want {
+		t.Errorf("query = %s, want %s", recorder.queries[0], want)
+	}
+	if want := []any{int64(7), "guest"}; !reflect.DeepEqual(recorder.args[0], want) {
+		t.Errorf("arguments = %v, want %v", recorder.args[0], want)
+	}
+}
+
+func TestSoftDeleteRequiresPredicate(t *testing.T) {
+	db := newFakeDriver(nil).client(t)
+	for name, exec := range map[string]Exec{
+		"no table":     db.SoftDelete("", "id", 1),
+		"no predicate": db.SoftDelete("users"),
+		"odd pairs":    db.SoftDelete("users", "id"),
+	} {
+		if _, err := exec.ExecUpdate(context.Background()); err == nil {
+			t.Errorf("SoftDelete with %s succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// statementCache is a least-recently-used cache of named statements prepared on a pool,
+// keyed by pool and query text. It is safe for concurrent use.
+//
+// A statement evicted or invalidated while in use is closed once its last user releases it.
+type statementCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[statementKey]*list.Element
+	order   *list.List // Most recently used at the front
+}
+
+// statementKey identifies a statement prepared on a pool.
+type statementKey struct {
+	database *sqlx.DB
+	query    string
+}
+
+// cachedStatement is a prepared statement held by the cache.
+type cachedStatement struct {
+	key       statementKey
+	statement *sqlx.NamedStmt
+	refs      int  // Number of callers currently using the statement
+	evicted   bool // Removed from the cache, close once refs drops to zero
+}
+
+// newStatementCache creates a statement cache holding at most size statements.
+func newStatementCache(size int) *statementCache {
+	return &statementCache{
+		size:    size,
+		entries: make(map[statementKey]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// acquire returns the cached statement for key and marks it in use, or nil if there is none.
+func (c *statementCache) acquire(key statementKey) *cachedStatement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		return nil
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*cachedStatement)
+	entry.refs++
+	return entry
+}
+
+// add caches statement under key, evicting the least recently used statement if the cache is full,
+// and returns the entry marked in use. If another caller cached the key first, statement is closed
+// and the existing entry is returned instead.
+func (c *statementCache) add(key statementKey, statement *sqlx.NamedStmt) *cachedStatement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.entries[key]; exists {
+		_ = statement.Close()
+		c.order.MoveToFront(element)
+		entry := element.Value.(*cachedStatement)
+		entry.refs++
+		return entry
+	}
+
+	for c.order.Len() >= c.size {
+		c.evictLocked(c.order.Back())
+	}
+
+	entry := &cachedStatement{key: key, statement: statement, refs: 1}
+	c.entries[key] = c.order.PushFront(entry)
+	return entry
+}
+
+// release marks entry as no longer in use. If invalidate is true the entry is removed
+// from the cache, so the next caller prepares the query again.
+func (c *statementCache) release(entry *cachedStatement, invalidate bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.refs--
+	if invalidate && !entry.evicted {
+		if element, exists := c.entries[entry.key]; exists && element.Value == entry {
+			c.evictLocked(element)
+			return nil
+		}
+	}
+	if entry.evicted && entry.refs == 0 {
+		return errors.WithStack(entry.statement.Close())
+	}
+	return nil
+}
+
+// close removes every statement from the cache, closing those not in use.
+func (c *statementCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.order.Len() > 0 {
+		c.evictLocked(c.order.Back())
+	}
+}
+
+// evictLocked removes element from the cache and closes its statement if it is not in use.
+func (c *statementCache) evictLocked(element *list.Element) {
+	entry := c.order.Remove(element).(*cachedStatement)
+	delete(c.entries, entry.key)
+	entry.evicted = true
+	if entry.refs == 0 {
+		_ = entry.statement.Close()
+	}
+}
+
+// isConnectionError returns true if err means the connection behind a statement is unusable.
+func isConnectionError(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// retryBadConn calls fn once more if it fails with a connection error and ctx is still live,
+// e.g. for the first query after a failover. fn must be safe to run twice, so it is only
+// used for reads.
+func retryBadConn(ctx context.Context, fn func() error) error {
+	err := fn()
+	if err != nil && isConnectionError(err) && ctx.Err() == nil {
+		err = fn()
+	}
+	return err
+}
+
+// truncateSlice sets the length of the slice destination points to, if any, to 0, so a retried
+// read does not keep the rows a failed attempt scanned before its connection broke.
+func truncateSlice(destination any) {
+	value := reflect.ValueOf(destination)
+	if value.Kind() == reflect.Pointer && !value.IsNil() && value.Elem().Kind() == reflect.Slice {
+		value.Elem().SetLen(0)
+	}
+}
+
+// prepareNamed prepares query on database, reusing a cached statement when the statement
+// cache is enabled. The returned release func must be called with the error, if any,
+// of using the statement once the caller is done with it.
+func (postgresInstance *postgres) prepareNamed(ctx context.Context, database *sqlx.DB, query string) (*sqlx.NamedStmt, func(err error) error, error) {
+	key := statementKey{database: database, query: query}
+	cache := postgresInstance.statements
+	if cache != nil {
+		if entry := cache.acquire(key); entry != nil {
+			return entry.statement, func(err error) error {
+				return cache.release(entry, isConnectionError(err))
+			}, nil
+		}
+	}
+
+	preparedStatement, err := database.PrepareNamedContext(ctx, escapeNamedQuery(query))
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if cache == nil {
+		return preparedStatement, func(error) error {
+			return errors.WithStack(preparedStatement.Close())
+		}, nil
+	}
+
+	entry := cache.add(key, preparedStatement)
+	return entry.statement, func(err error) error {
+		return cache.release(entry, isConnectionError(err))
+	}, nil
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+)
+
+// selectOne answers every query with a single id column row.
+func selectOne(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+	return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}}, nil
+}
+
+func TestStatementCacheReusesStatement(t *testing.T) {
+	fake := newFakeDriver(selectOne)
+	db := fake.client(t, WithStatementCache(8), WithMaxOpenConns(1))
+	ctx := context.Background()
+
+	for range 5 {
+		var ids []int64
+		if _, err := db.Select("SELECT id FROM t WHERE id = :id", &ids, "id", 1).Many(ctx); err != nil {
+			t.Fatalf("Many: %v", err)
+		}
+	}
+	if prepares := fake.prepares.Load(); prepares != 1 {
+		t.Errorf("5 runs of one query prepared %d statements, want 1", prepares)
+	}
+	if closes := fake.closes.Load(); closes != 0 {
+		t.Errorf("closed %d cached statements after use, want 0", closes)
+	}
+
+	var ids []int64
+	if _, err := db.Select("SELECT id FROM t WHERE owner = :owner", &ids, "owner", 1).Many(ctx); err != nil {
+		t.Fatalf("Many: %v", err)
+	}
+	if prepares := fake.prepares.Load(); prepares != 2 {
+		t.Errorf("a second query prepared %d statements in total, want 2", prepares)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if closes := fake.closes.Load(); closes != 2 {
+		t.Errorf("Close closed %d cached statements, want 2", closes)
+	}
+}
+
+func TestStatementCacheInvalidatesOnConnectionError(t *testing.T) {
+	fake := newFakeDriver(selectOne)
+	db := fake.client(t, WithStatementCache(8))
+	ctx := context.Background()
+	query := "SELECT id FROM t WHERE id = :id"
+
+	_, release, err := db.prepareNamed(ctx, db.database, query)
+	if err != nil {
+		t.Fatalf("prepareNamed: %v", err)
+	}
+	if err = release(driver.ErrBadConn); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if closes := fake.closes.Load(); closes != 1 {
+		t.Errorf("invalidated statement closed %d times, want 1", closes)
+	}
+
+	_, release, err = db.prepareNamed(ctx, db.database, query)
+	if err != nil {
+		t.Fatalf("prepareNamed: %v", err)
+	}
+	_ = release(nil)
+	if prepares := fake.prepares.Load(); prepares != 2 {
+		t.Errorf("query prepared %d times after invalidation, want 2", prepares)
+	}
+}
+
+func TestStatementCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	fake := newFakeDriver(selectOne)
+	db := fake.client(t, WithStatementCache(1))
+	ctx := context.Background()
+
+	first, release, err := db.prepareNamed(ctx, db.database, "SELECT 1")
+	if err != nil {
+		t.Fatalf("prepareNamed: %v", err)
+	}
+	// first stays in use while it is evicted, so it is only closed when released
+	if _, releaseSecond, err := db.prepareNamed(ctx, db.database, "SELECT 2"); err != nil {
+		t.Fatalf("prepareNamed: %v", err)
+	} else {
+		_ = releaseSecond(nil)
+	}
+	if closes := fake.closes.Load(); closes != 0 {
+		t.Errorf("statement in use closed on eviction")
+	}
+	var id int64
+	if err = first.GetContext(ctx, &id, map[string]any{}); err != nil {
+		t.Errorf("evicted statement in use failed: %v", err)
+	}
+	if err = release(nil); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if closes := fake.closes.Load(); closes != 1 {
+		t.Errorf("evicted statement closed %d times once released, want 1", closes)
+	}
+}
+
+// BenchmarkSelectStatementCache compares running a query with the statement cache to
+// preparing and closing it on every call.
+func BenchmarkSelectStatementCache(b *testing.B) {
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"cached", []Option{WithStatementCache(16)}},
+		{"uncached", nil},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			fake := newFakeDriver(selectOne)
+			db := fake.client(b, mode.opts...)
+			ctx := context.Background()
+
+			for b.Loop() {
+				var id int64
+				if _, err := db.Select("SELECT id FROM t WHERE id = :id", &id, "id", 1).One(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(fake.prepares.Load())/float64(b.N), "prepares/op")
+		})
+	}
+}
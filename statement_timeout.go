@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// statementTimeoutSetting is the configuration parameter set by WithStatementTimeout.
+const statementTimeoutSetting = "statement_timeout"
+
+// statementTimeoutValue returns timeout in whole milliseconds, rounded up so a timeout
+// below one millisecond does not become 0, which disables statement_timeout.
+func statementTimeoutValue(timeout time.Duration) string {
+	milliseconds := (timeout + time.Millisecond - 1) / time.Millisecond
+	return strconv.FormatInt(int64(milliseconds), 10)
+}
+
+// withStatementTimeout runs fn in a transaction on database in which the server cancels
+// any statement running longer than timeout. A cancelled statement returns an error
+// matching ErrStatementTimeout.
+func (postgresInstance *postgres) withStatementTimeout(ctx context.Context, database *sqlx.DB, timeout time.Duration, debug bool, fn func(transaction *sqlx.Tx) error) error {
+	err := postgresInstance.transact(ctx, database, nil, func(t *transaction) error {
+		if err := setLocalTx(ctx, postgresInstance, t.tx, statementTimeoutSetting, statementTimeoutValue(timeout), debug); err != nil {
+			return err
+		}
+		return fn(t.tx)
+	})
+	return markStatementTimeout(ctx, err)
+}
+
+// beginWithTimeout begins a transaction on database with statement_timeout set to timeout.
+// The returned end func commits the transaction if it was used without error and rolls it back
+// otherwise; it is shaped like the release func of prepareNamed so an Iterator can own it.
+func (postgresInstance *postgres) beginWithTimeout(ctx context.Context, database *sqlx.DB, timeout time.Duration, debug bool) (*sqlx.Tx, func(err error) error, error) {
+	transaction, err := database.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if err = setLocalTx(ctx, postgresInstance, transaction, statementTimeoutSetting, statementTimeoutValue(timeout), debug); err != nil {
+		return nil, nil, joinRollbackError(err, transaction.Rollback())
+	}
+
+	return transaction, func(err error) error {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			if rollbackErr := transaction.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+				return errors.WithStack(rollbackErr)
+			}
+			return nil
+		}
+		return errors.WithStack(transaction.Commit())
+	}, nil
+}
+
+// prepareNamedWithTimeout prepares query in a transaction begun by beginWithTimeout, like
+// prepareNamed does on the pool. The returned release func closes the statement and ends the transaction.
+func (postgresInstance *postgres) prepareNamedWithTimeout(ctx context.Context, database *sqlx.DB, query string, timeout time.Duration, debug bool) (*sqlx.NamedStmt, func(err error) error, error) {
+	transaction, end, err := postgresInstance.beginWithTimeout(ctx, database, timeout, debug)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	preparedStatement, err := transaction.PrepareNamedContext(ctx, escapeNamedQuery(query))
+	if err != nil {
+		err = errors.WithStack(err)
+		_ = end(err)
+		return nil, nil, err
+	}
+
+	return preparedStatement, func(err error) error {
+		_ = preparedStatement.Close()
+		return end(err)
+	}, nil
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// sleepServer answers SELECT pg_sleep(:seconds) like a server honoring statement_timeout: a sleep
+// longer than the timeout of the session is cancelled with SQLSTATE 57014 once the timeout passes.
+func sleepServer(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	session := fakeSessionFrom(ctx)
+	switch {
+	case strings.HasPrefix(query, "SELECT set_config("):
+		session.local[args[0].Value.(string)] = args[1].Value.(string)
+		return fakeResult{columns: []string{"set_config"}, rows: [][]driver.Value{{args[1].Value}}}, nil
+	case strings.Contains(query, "pg_sleep("):
+		sleep := time.Duration(args[0].Value.(float64) * float64(time.Second))
+		if milliseconds, err := strconv.Atoi(session.setting(statementTimeoutSetting)); err == nil && milliseconds > 0 {
+			if timeout := time.Duration(milliseconds) * time.Millisecond; sleep > timeout {
+				time.Sleep(timeout)
+				return fakeResult{}, &pq.Error{Code: sqlStateQueryCanceled, Message: "canceling statement due to statement timeout"}
+			}
+		}
+		time.Sleep(sleep)
+		return fakeResult{columns: []string{"pg_sleep"}, rows: [][]driver.Value{{""}}, rowsAffected: 1}, nil
+	}
+	return returningOne(ctx, query, args)
+}
+
+func TestStatementTimeoutCancelsSlowQueries(t *testing.T) {
+	fake := newFakeDriver(sleepServer)
+	db := fake.client(t)
+	ctx := context.Background()
+
+	var slept string
+	_, err := db.Select("SELECT pg_sleep(:seconds)", &slept, "seconds", 1.0).WithStatementTimeout(20 * time.Millisecond).One(ctx)
+	if !errors.Is(err, ErrStatementTimeout) {
+		t.Fatalf("One = %v, want ErrStatementTimeout", err)
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != sqlStateQueryCanceled {
+		t.Errorf("One = %v, want it to unwrap to the driver error", err)
+	}
+
+	_, err = db.Update("UPDATE jobs SET done = true WHERE id = :id", "id", 1).
+		Update("UPDATE jobs SET slept = pg_sleep(:seconds) WHERE id = :id", "seconds", 1.0, "id", 1).
+		WithStatementTimeout(20 * time.Millisecond).
+		ExecInTx(ctx)
+	if !errors.Is(err, ErrStatementTimeout) {
+		t.Errorf("ExecInTx = %v, want ErrStatementTimeout", err)
+	}
+
+	if _, err = db.Select("SELECT pg_sleep(:seconds)", &slept, "seconds", 0.001).WithStatementTimeout(time.Second).One(ctx); err != nil {
+		t.Errorf("One within the timeout = %v, want no error", err)
+	}
+	if _, err = db.Select("SELECT pg_sleep(:seconds)", &slept, "seconds", 0.03).One(ctx); err != nil {
+		t.Errorf("One without a timeout = %v, want no error", err)
+	}
+}
+
+func TestStatementTimeoutValue(t *testing.T) {
+	tests := map[time.Duration]string{
+		2 * time.Second:         "2000",
+		1500 * time.Microsecond: "2",
+		time.Nanosecond:         "1",
+	}
+	for timeout, want := range tests {
+		if got := statementTimeoutValue(timeout); got != want {
+			t.Errorf("statementTimeoutValue(%v) = %s, want %s", timeout, got, want)
+		}
+	}
+}
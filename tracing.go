@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	dbSystemPostgres = "postgresql"
+)
+
+// startSpan starts a client span named name for query, as a child of the span in ctx,
+// when a tracer is configured. The statement is recorded with its named placeholders;
+// argument values are never recorded. The returned func ends the span and records err on it.
+func (postgresInstance *postgres) startSpan(ctx context.Context, name, query string) (context.Context, func(err error)) {
+	if postgresInstance.tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := postgresInstance.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", dbSystemPostgres),
+			attribute.String("db.operation", queryType(query)),
+			attribute.String("db.statement", query),
+		),
+	)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingTracer records the spans it starts in memory.
+type recordingTracer struct {
+	noop.Tracer
+
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+// recordingSpan is a span started by recordingTracer.
+type recordingSpan struct {
+	noop.Span
+
+	name       string
+	kind       trace.SpanKind
+	parent     trace.Span
+	attributes map[attribute.Key]attribute.Value
+	status     codes.Code
+	errs       []error
+	ended      bool
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	span := &recordingSpan{
+		name:       name,
+		kind:       config.SpanKind(),
+		parent:     trace.SpanFromContext(ctx),
+		attributes: make(map[attribute.Key]attribute.Value),
+	}
+	for _, attribute := range config.Attributes() {
+		span.attributes[attribute.Key] = attribute.Value
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (t *recordingTracer) recorded() []*recordingSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*recordingSpan(nil), t.spans...)
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) { s.status = code }
+
+func (s *recordingSpan) RecordError(err error, _ ...trace.EventOption) { s.errs = append(s.errs, err) }
+
+func (s *recordingSpan) End(...trace.SpanEndOption) { s.ended = true }
+
+func TestSpanPerQuery(t *testing.T) {
+	tracer := &recordingTracer{}
+	db := newFakeDriver(selectOne).client(t, WithTracer(tracer))
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	var id int64
+	if _, err := db.Select("SELECT id FROM users WHERE id = :id", &id, "id", 1).One(ctx); err != nil {
+		t.Fatalf("One: %v", err)
+	}
+	var ids []int64
+	if _, err := db.Select("SELECT id FROM users", &ids).Many(ctx); err != nil {
+		t.Fatalf("Many: %v", err)
+	}
+	_, err := db.Insert("INSERT INTO users (name) VALUES (:name) RETURNING id", "name", "Alice").
+		Update("UPDATE users SET active = :active", "active", true).
+		ExecInTx(ctx)
+	if err != nil {
+		t.Fatalf("ExecInTx: %v", err)
+	}
+
+	spans := tracer.recorded()[1:]
+	wantNames := []string{"postgres.One", "postgres.Many", "postgres.ExecInTx"}
+	if len(spans) != len(wantNames) {
+		t.Fatalf("recorded %d query spans, want %d", len(spans), len(wantNames))
+	}
+	for i, span := range spans {
+		if span.name != wantNames[i] || span.kind != trace.SpanKindClient || span.parent != parent || !span.ended {
+			t.Errorf("span %d = %s of kind %v, ended %v, want an ended client span %s under the request span", i, span.name, span.kind, span.ended, wantNames[i])
+		}
+		if system := span.attributes["db.system"].AsString(); system != dbSystemPostgres {
+			t.Errorf("span %s has db.system %q, want %q", span.name, system, dbSystemPostgres)
+		}
+	}
+	if statement := spans[0].attributes["db.statement"].AsString(); statement != "SELECT id FROM users WHERE id = :id" {
+		t.Errorf("db.statement = %q, want the query with its named placeholders", statement)
+	}
+}
+
+func TestSpanRecordsError(t *testing.T) {
+	tracer := &recordingTracer{}
+	driverErr := errors.New("connection reset")
+	db := newFakeDriver(func(context.Context, string, []driver.NamedValue) (fakeResult, error) {
+		return fakeResult{}, driverErr
+	}).client(t, WithTracer(tracer))
+
+	var id int64
+	if _, err := db.Select("SELECT id FROM users", &id).One(context.Background()); err == nil {
+		t.Fatal("One succeeded, want the driver error")
+	}
+
+	spans := tracer.recorded()
+	if len(spans) != 1 || spans[0].status != codes.Error || len(spans[0].errs) != 1 || !errors.Is(spans[0].errs[0], driverErr) {
+		t.Errorf("spans = %+v, want one span with the driver error recorded", spans)
+	}
+}
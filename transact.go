@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ReadTx is a read-only transaction opened by ReadTx. Its methods run immediately within
+// the transaction and all see the same snapshot of the database.
+type ReadTx interface {
+	Select(ctx context.Context, query string, destination any, keyValuePairs ...any) (found bool, err error)
+	Scalar(ctx context.Context, query string, destination any, keyValuePairs ...any) error
+}
+
+// Tx is a transaction opened by Transact. Its methods run immediately within the
+// transaction and return their results inline.
+type Tx interface {
+	ReadTx
+	Insert(ctx context.Context, query string, keyValuePairs ...any) (any, error)
+	Update(ctx context.Context, query string, keyValuePairs ...any) (int64, error)
+	Delete(ctx context.Context, query string, keyValuePairs ...any) (int64, error)
+}
+
+// transaction is the Tx passed to a Transact function and the ReadTx passed to a ReadTx function.
+type transaction struct {
+	postgres *postgres
+	tx       *sqlx.Tx
+}
+
+// Transact runs fn in a transaction. The transaction is committed if fn returns nil and
+// rolled back if it returns an error or panics; a panic is re-raised after the rollback.
+// Use it instead of a pipeline when later statements depend on earlier results in ways
+// FromResult cannot express, such as branching.
+//
+// Example:
+//
+//	err := db.Transact(ctx, func(tx postgres.Tx) error {
+//		id, err := tx.Insert(ctx, "INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book")
+//		if err != nil {
+//			return err
+//		}
+//		_, err = tx.Insert(ctx, "INSERT INTO order_lines (order_id) VALUES (:order_id) RETURNING id", "order_id", id)
+//		return err
+//	})
+func (postgresInstance *postgres) Transact(ctx context.Context, fn func(tx Tx) error) error {
+	return postgresInstance.transact(ctx, postgresInstance.database, nil, func(t *transaction) error {
+		return fn(t)
+	})
+}
+
+// ReadTx runs fn in a read-only repeatable read transaction, so every query in fn sees the
+// same snapshot, e.g. for a report made of several selects. The transaction runs on a read
+// replica when one is configured. Writes inside it are rejected by the server.
+func (postgresInstance *postgres) ReadTx(ctx context.Context, fn func(tx ReadTx) error) error {
+	options := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	return postgresInstance.transact(ctx, postgresInstance.reader(false), options, func(t *transaction) error {
+		return fn(t)
+	})
+}
+
+// transact runs fn in a transaction on database, committing if fn returns nil and rolling
+// back if it returns an error or panics.
+func (postgresInstance *postgres) transact(ctx context.Context, database *sqlx.DB, options *sql.TxOptions, fn func(t *transaction) error) (err error) {
+	ctx, cancel := postgresInstance.withDefaultTimeout(ctx)
+	defer cancel()
+
+	sqlxTx, err := database.BeginTxx(ctx, options)
+	if err != nil {
+		return postgresInstance.wrapError(errors.WithStack(err))
+	}
+	defer func() {
+		if panicValue := recover(); panicValue != nil {
+			_ = sqlxTx.Rollback()
+			panic(panicValue)
+		} else if err != nil {
+			err = postgresInstance.wrapError(joinRollbackError(err, sqlxTx.Rollback()))
+		} else {
+			err = postgresInstance.wrapError(errors.WithStack(sqlxTx.Commit()))
+		}
+	}()
+
+	return fn(&transaction{postgres: postgresInstance, tx: sqlxTx})
+}
+
+// Insert runs an INSERT ... RETURNING query and returns the returned ID.
+func (t *transaction) Insert(ctx context.Context, query string, keyValuePairs ...any) (id any, err error) {
+	err = t.run(ctx, query, keyValuePairs, func(statement string, arguments map[string]any) (int64, error) {
+		id, err = insertTx(ctx, &txStatements{tx: t.tx}, statement, arguments)
+		return 1, err
+	})
+	return id, err
+}
+
+// Update runs an UPDATE query and returns the number of affected rows.
+func (t *transaction) Update(ctx context.Context, query string, keyValuePairs ...any) (rowsAffected int64, err error) {
+	err = t.run(ctx, query, keyValuePairs, func(statement string, arguments map[string]any) (int64, error) {
+		rowsAffected, err = updateTx(ctx, &txStatements{tx: t.tx}, statement, arguments)
+		return rowsAffected, err
+	})
+	return rowsAffected, err
+}
+
+// Delete runs a DELETE query and returns the number of affected rows.
+func (t *transaction) Delete(ctx context.Context, query string, keyValuePairs ...any) (rowsAffected int64, err error) {
+	err = t.run(ctx, query, keyValuePairs, func(statement string, arguments map[string]any) (int64, error) {
+		rowsAffected, err = deleteTx(ctx, &txStatements{tx: t.tx}, statement, arguments)
+		return rowsAffected, err
+	})
+	return rowsAffected, err
+}
+
+// Select scans a single row, or all rows when destination points to a slice, into destination.
+// It returns false if no row is found.
+func (t *transaction) Select(ctx context.Context, query string, destination any, keyValuePairs ...any) (found bool, err error) {
+	err = t.run(ctx, query, keyValuePairs, func(statement string, arguments map[string]any) (int64, error) {
+		result, err := selectTx(ctx, &txStatements{tx: t.tx}, statement, arguments, destination)
+		found = err == nil && result != nil
+		return selectedRows(found, destination), err
+	})
+	return found, err
+}
+
+// Scalar selects a single value into destination.
+// It returns an error matching both ErrNotFound and sql.ErrNoRows when no row is found.
+func (t *transaction) Scalar(ctx context.Context, query string, destination any, keyValuePairs ...any) error {
+	found, err := t.Select(ctx, query, destination, keyValuePairs...)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return t.postgres.wrapError(errors.WithStack(errNoRows))
+	}
+	return nil
+}
+
+// run resolves the arguments of query and calls execute with the statement to run,
+// reporting it to the configured logger and observer like any other query.
+func (t *transaction) run(ctx context.Context, query string, keyValuePairs []any, execute func(statement string, arguments map[string]any) (int64, error)) (err error) {
+	ctx, cancel := t.postgres.withDefaultTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = t.postgres.wrapError(err)
+	}()
+
+	arguments, err := pairsWithMapper(keyValuePairs, t.postgres.fieldMapper)
+	if err != nil {
+		return err
+	}
+	if err = checkArguments(query, arguments); err != nil {
+		return err
+	}
+	statement, arguments := expandInClauses(query, arguments)
+
+	t.postgres.beforeQuery(ctx, false, statement, arguments)
+	started := time.Now()
+	rowsAffected, err := execute(statement, arguments)
+	if err != nil {
+		rowsAffected = 0
+	}
+	t.postgres.afterQuery(ctx, statement, arguments, started, rowsAffected, err)
+	return err
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTransactCommits(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t)
+	ctx := context.Background()
+
+	var id any
+	err := db.Transact(ctx, func(tx Tx) error {
+		var err error
+		if id, err = tx.Insert(ctx, "INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book"); err != nil {
+			return err
+		}
+		_, err = tx.Update(ctx, "UPDATE stock SET count = count - 1 WHERE item = :item", "item", "book")
+		return err
+	})
+	if err != nil || id != int64(1) {
+		t.Fatalf("Transact = %v with id %v, want id 1", err, id)
+	}
+	if commits, rollbacks := fake.commits.Load(), fake.rollbacks.Load(); commits != 1 || rollbacks != 0 {
+		t.Errorf("%d commits and %d rollbacks, want 1 commit", commits, rollbacks)
+	}
+}
+
+func TestTransactRollsBackOnError(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t)
+	ctx := context.Background()
+
+	outOfStock := errors.New("out of stock")
+	err := db.Transact(ctx, func(tx Tx) error {
+		if _, err := tx.Insert(ctx, "INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book"); err != nil {
+			return err
+		}
+		return outOfStock
+	})
+	if !errors.Is(err, outOfStock) {
+		t.Fatalf("Transact = %v, want the error of fn", err)
+	}
+	if commits, rollbacks := fake.commits.Load(), fake.rollbacks.Load(); commits != 0 || rollbacks != 1 {
+		t.Errorf("%d commits and %d rollbacks, want 1 rollback", commits, rollbacks)
+	}
+}
+
+func TestTransactRollsBackOnPanic(t *testing.T) {
+	fake := newFakeDriver(nil)
+	db := fake.client(t)
+	ctx := context.Background()
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "boom" {
+				t.Errorf("recovered %v, want the panic re-raised", recovered)
+			}
+		}()
+		_ = db.Transact(ctx, func(tx Tx) error {
+			if _, err := tx.Insert(ctx, "INSERT INTO orders (item) VALUES (:item) RETURNING id", "item", "book"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+	if commits, rollbacks := fake.commits.Load(), fake.rollbacks.Load(); commits != 0 || rollbacks != 1 {
+		t.Errorf("%d commits and %d rollbacks, want 1 rollback", commits, rollbacks)
+	}
+}
+
+// readOnlyServer answers like a server honoring read-only transactions: writes in one fail
+// with SQLSTATE 25006, and SHOW transaction_isolation returns the isolation of the transaction.
+func readOnlyServer(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	session := fakeSessionFrom(ctx)
+	if strings.HasPrefix(query, "SHOW ") {
+		return fakeResult{columns: []string{"setting"}, rows: [][]driver.Value{{session.setting(strings.TrimPrefix(query, "SHOW "))}}}, nil
+	}
+	if queryType(query) != qSelect && session.setting("transaction_read_only") == "on" {
+		return fakeResult{}, fmt.Errorf("pq: cannot execute %s in a read-only transaction (SQLSTATE 25006)", strings.ToUpper(queryType(query)))
+	}
+	return returningOne(ctx, query, args)
+}
+
+func TestReadTxRejectsWrites(t *testing.T) {
+	fake := newFakeDriver(readOnlyServer)
+	db := fake.client(t)
+	ctx := context.Background()
+
+	var isolation string
+	err := db.ReadTx(ctx, func(tx ReadTx) error {
+		return tx.Scalar(ctx, "SHOW transaction_isolation", &isolation)
+	})
+	if err != nil || isolation != "Repeatable Read" {
+		t.Errorf("ReadTx isolation = %q, %v, want a repeatable read snapshot", isolation, err)
+	}
+
+	err = db.ReadTx(ctx, func(tx ReadTx) error {
+		var id int64
+		_, err := tx.Select(ctx, "INSERT INTO orders (item) VALUES (:item) RETURNING id", &id, "item", "book")
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "read-only transaction") {
+		t.Errorf("write in ReadTx = %v, want it rejected", err)
+	}
+	if commits, rollbacks := fake.commits.Load(), fake.rollbacks.Load(); commits != 1 || rollbacks != 1 {
+		t.Errorf("%d commits and %d rollbacks, want the read committed and the write rolled back", commits, rollbacks)
+	}
+}
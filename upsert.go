@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Upsert builds an INSERT ... ON CONFLICT statement for one row of table.
+// values maps column names to values. On a conflict on conflictColumns, updateColumns are set
+// to the values of the proposed row through EXCLUDED; with no updateColumns the row is left
+// unchanged (DO NOTHING).
+//
+// ExecUpdate returns the number of rows inserted or updated, which is 0 when nothing changed.
+// Write the statement by hand with a RETURNING clause and use Insert to get the row's ID instead.
+//
+// Example:
+//
+//	db.Upsert("users", []string{"email"}, map[string]any{"email": email, "name": name}, []string{"name"}).ExecUpdate(ctx)
+func (postgresInstance *postgres) Upsert(table string, conflictColumns []string, values map[string]any, updateColumns []string) Exec {
+	query, keyValuePairs, err := buildUpsert(table, conflictColumns, values, updateColumns)
+
+	exec := newExecQuery(postgresInstance, query, keyValuePairs).(*execQuery)
+	exec.noReturn = true
+	exec.err = err
+	return exec
+}
+
+// buildUpsert builds the INSERT ... ON CONFLICT statement and its key-value pairs.
+func buildUpsert(table string, conflictColumns []string, values map[string]any, updateColumns []string) (string, []any, error) {
+	if table == "" {
+		return "", nil, errors.New("upsert requires a table name")
+	}
+	if len(values) == 0 {
+		return "", nil, errors.New("upsert requires at least one column")
+	}
+	if len(conflictColumns) == 0 {
+		return "", nil, errors.New("upsert requires at least one conflict column")
+	}
+
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	quotedColumns := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	keyValuePairs := make([]any, 0, len(columns)*2)
+	for i, column := range columns {
+		key := fmt.Sprintf("p%d", i)
+		quotedColumns[i] = quoteIdentifier(column)
+		placeholders[i] = ":" + key
+		keyValuePairs = append(keyValuePairs, key, values[column])
+	}
+
+	quotedConflictColumns := make([]string, len(conflictColumns))
+	for i, column := range conflictColumns {
+		quotedConflictColumns[i] = quoteIdentifier(column)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("INSERT INTO ")
+	builder.WriteString(quoteIdentifier(table))
+	builder.WriteString(" (")
+	builder.WriteString(strings.Join(quotedColumns, ", "))
+	builder.WriteString(") VALUES (")
+	builder.WriteString(strings.Join(placeholders, ", "))
+	builder.WriteString(") ON CONFLICT (")
+	builder.WriteString(strings.Join(quotedConflictColumns, ", "))
+	builder.WriteString(")")
+
+	if len(updateColumns) == 0 {
+		builder.WriteString(" DO NOTHING")
+		return builder.String(), keyValuePairs, nil
+	}
+
+	assignments := make([]string, len(updateColumns))
+	for i, column := range updateColumns {
+		if _, ok := values[column]; !ok {
+			return "", nil, errors.Errorf("upsert update column %q is not in values", column)
+		}
+		quoted := quoteIdentifier(column)
+		assignments[i] = quoted + " = EXCLUDED." + quoted
+	}
+	builder.WriteString(" DO UPDATE SET ")
+	builder.WriteString(strings.Join(assignments, ", "))
+
+	return builder.String(), keyValuePairs, nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// usersByEmail is a users table with a unique email, answering the upserts built for it.
+// The arguments of an upsert are its columns in sorted order: email, then name.
+type usersByEmail struct {
+	mu    sync.Mutex
+	names map[string]string
+}
+
+func (u *usersByEmail) handle(_ context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	email, name := args[0].Value.(string), args[1].Value.(string)
+	if _, conflict := u.names[email]; conflict {
+		if strings.HasSuffix(query, "DO NOTHING") {
+			return fakeResult{rowsAffected: 0}, nil
+		}
+	}
+	u.names[email] = name
+	return fakeResult{rowsAffected: 1}, nil
+}
+
+func TestUpsertInsertsThenUpdates(t *testing.T) {
+	table := &usersByEmail{names: map[string]string{}}
+	db := newFakeDriver(table.handle).client(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"Alice", "Alice Smith"} {
+		rows, err := db.Upsert("users", []string{"email"}, map[string]any{"email": "alice@example.com", "name": name}, []string{"name"}).ExecUpdate(ctx)
+		if err != nil || rows != 1 {
+			t.Fatalf("Upsert(%s) = %d, %v, want 1 row", name, rows, err)
+		}
+		if table.names["alice@example.com"] != name {
+			t.Errorf("after Upsert(%s) the name is %q", name, table.names["alice@example.com"])
+		}
+	}
+
+	rows, err := db.Upsert("users", []string{"email"}, map[string]any{"email": "alice@example.com", "name": "Bob"}, nil).ExecUpdate(ctx)
+	if err != nil || rows != 0 || table.names["alice@example.com"] != "Alice Smith" {
+		t.Errorf("Upsert without update columns = %d, %v, name %q, want the row left unchanged", rows, err, table.names["alice@example.com"])
+	}
+}
+
+func TestBuildUpsert(t *testing.T) {
+	query, keyValuePairs, err := buildUpsert("users", []string{"email"}, map[string]any{"name": "Alice", "email": "alice@example.com"}, []string{"name"})
+	if err != nil {
+		t.Fatalf("buildUpsert: %v", err)
+	}
+	if want := `INSERT INTO "users" ("email", "name") VALUES (:p0, :p1) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`; query != want {
+		t.Errorf("query = %s, want %s", query, want)
+	}
+	if len(keyValuePairs) != 4 || keyValuePairs[1] != "alice@example.com" || keyValuePairs[3] != "Alice" {
+		t.Errorf("key-value pairs = %v, want the values in column order", keyValuePairs)
+	}
+
+	invalid := map[string]func() (string, []any, error){
+		"no table": func() (string, []any, error) {
+			return buildUpsert("", []string{"email"}, map[string]any{"email": 1}, nil)
+		},
+		"no values":           func() (string, []any, error) { return buildUpsert("users", []string{"email"}, nil, nil) },
+		"no conflict columns": func() (string, []any, error) { return buildUpsert("users", nil, map[string]any{"email": 1}, nil) },
+		"unknown update column": func() (string, []any, error) {
+			return buildUpsert("users", []string{"email"}, map[string]any{"email": 1}, []string{"name"})
+		},
+	}
+	for name, build := range invalid {
+		if _, _, err := build(); err == nil {
+			t.Errorf("buildUpsert with %s succeeded, want an error", name)
+		}
+	}
+}
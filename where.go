@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	whereKeyPrefix = generatedKeyPrefix + "where_"
+)
+
+// Where builds a dynamic WHERE clause from AND-ed conditions.
+// Columns are quoted as identifiers and values are bound through generated named parameters,
+// so neither is interpolated into SQL. Where is not safe for concurrent use.
+//
+// The parameters are numbered per builder, __where_0, __where_1 and so on, so they cannot
+// collide with the caller's keys. Two builders bind the same keys: passing the Kv of both to
+// one query fails, so add every condition of a query to a single builder.
+type Where struct {
+	conditions []string
+	arguments  map[string]any
+}
+
+// NewWhere creates an empty WHERE clause builder.
+//
+// Example:
+//
+//	where := postgres.NewWhere().Eq("status", "active").In("role", roles).Like("name", "jo%")
+//	clause, _ := where.Build()
+//	db.Select("SELECT * FROM users WHERE "+clause, &users, where.Kv()...).Many(ctx)
+func NewWhere() *Where {
+	return &Where{arguments: make(map[string]any)}
+}
+
+// Eq adds a column = value condition.
+func (w *Where) Eq(column string, value any) *Where {
+	return w.add(column, "= :%s", value)
+}
+
+// In adds a column IN (values) condition. values must be a slice.
+// An empty slice matches no rows.
+func (w *Where) In(column string, values any) *Where {
+	if elements, ok := sliceElements(values); ok && len(elements) == 0 {
+		w.conditions = append(w.conditions, "FALSE")
+		return w
+	}
+	return w.add(column, "IN (:%s)", values)
+}
+
+// Like adds a column LIKE pattern condition. pattern is bound as is, so % and _ keep their meaning.
+func (w *Where) Like(column, pattern string) *Where {
+	return w.add(column, "LIKE :%s", pattern)
+}
+
+// Build returns the conditions joined with AND and the arguments they reference.
+// A builder without conditions returns TRUE, so the clause can always follow WHERE.
+func (w *Where) Build() (clause string, arguments map[string]any) {
+	arguments = make(map[string]any, len(w.arguments))
+	for key, value := range w.arguments {
+		arguments[key] = value
+	}
+	if len(w.conditions) == 0 {
+		return "TRUE", arguments
+	}
+	return strings.Join(w.conditions, " AND "), arguments
+}
+
+// Kv returns the arguments as key-value pairs, sorted by key, ready to pass to Select.
+func (w *Where) Kv() []any {
+	keys := make([]string, 0, len(w.arguments))
+	for key := range w.arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	keyValuePairs := make([]any, 0, len(keys)*2)
+	for _, key := range keys {
+		keyValuePairs = append(keyValuePairs, key, w.arguments[key])
+	}
+	return keyValuePairs
+}
+
+// add appends a condition on column, binding value under the next generated parameter name.
+func (w *Where) add(column, operator string, value any) *Where {
+	key := fmt.Sprintf("%s%d", whereKeyPrefix, len(w.arguments))
+	w.arguments[key] = value
+	w.conditions = append(w.conditions, quoteIdentifier(column)+" "+fmt.Sprintf(operator, key))
+	return w
+}
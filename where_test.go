@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWhereBuild(t *testing.T) {
+	where := NewWhere().Eq("status", "active").In("role", []string{"admin"}).Like("u.name", "jo%")
+	clause, arguments := where.Build()
+	if want := `"status" = :__where_0 AND "role" IN (:__where_1) AND "u"."name" LIKE :__where_2`; clause != want {
+		t.Errorf("Build() = %q, want %q", clause, want)
+	}
+	want := map[string]any{"__where_0": "active", "__where_1": []string{"admin"}, "__where_2": "jo%"}
+	if !reflect.DeepEqual(arguments, want) {
+		t.Errorf("Build() arguments = %v, want %v", arguments, want)
+	}
+
+	if clause, _ = NewWhere().In("id", []int{}).Build(); clause != "FALSE" {
+		t.Errorf("Build() with an empty In = %q, want FALSE", clause)
+	}
+	if clause, _ = NewWhere().Build(); clause != "TRUE" {
+		t.Errorf("Build() without conditions = %q, want TRUE", clause)
+	}
+}
+
+func TestWhereKeysDoNotCollide(t *testing.T) {
+	where := NewWhere().Eq("status", "active")
+	arguments, err := Pairs(append([]any{"where_0", "caller"}, where.Kv()...))
+	if err != nil {
+		t.Fatalf("Pairs: %v", err)
+	}
+	if arguments["where_0"] != "caller" || arguments["__where_0"] != "active" {
+		t.Errorf("Pairs() = %v, want the caller's where_0 kept", arguments)
+	}
+
+	other := NewWhere().Eq("role", "admin")
+	if _, err = Pairs(append(where.Kv(), other.Kv()...)); err == nil || !strings.Contains(err.Error(), "__where_0") {
+		t.Errorf("Pairs() with two builders = %v, want a generated key error", err)
+	}
+}